@@ -15,6 +15,13 @@ type AccessClaims struct {
 	jwt.RegisteredClaims
 }
 
+// UnsubscribeClaims name the user and the kind of email an unsubscribe link
+// is for.
+type UnsubscribeClaims struct {
+	Kind string `json:"kind"`
+	jwt.RegisteredClaims
+}
+
 func GetAccessClaimsFromAuthHeader(c *gin.Context, accessTokenSecret string) *AccessClaims {
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
@@ -1,61 +1,50 @@
 package auth
 
 import (
-	"crypto/tls"
+	"context"
 	"fmt"
-	"log"
+	"net/url"
 
 	"github.com/sharon-xa/high-api/internal/config"
-	"gopkg.in/gomail.v2"
+	"github.com/sharon-xa/high-api/internal/mail"
 )
 
-func SendVerificationEmail(userEmail, OTP string, env *config.Env) error {
-	htmlBody := generateTemplate(OTP)
-
-	m := gomail.NewMessage()
-	m.SetHeader("From", env.Email)
-	m.SetHeader("To", userEmail)
-	m.SetHeader("Subject", "High Account Verification")
-	m.SetBody("text/html", htmlBody)
-
-	d := gomail.NewDialer(
-		"smtp.hostinger.com",
-		465,
-		env.Email,
-		env.Password,
-	)
-	d.SSL = true
-	return d.DialAndSend(m)
+func SendVerificationEmail(
+	ctx context.Context,
+	mailer mail.Mailer,
+	userEmail, locale, OTP string,
+	env *config.Env,
+) error {
+	msg, err := mail.Compose(userEmail, locale, mail.TemplateVerification, mail.VerificationData{
+		Layout:       mail.Layout{SiteName: env.SiteName},
+		OTP:          OTP,
+		ExpiresInMin: env.OtpExpMin,
+	})
+	if err != nil {
+		return err
+	}
+	return mailer.Send(ctx, msg)
 }
 
-func SendResetPasswordEmail(email, token string, env *config.Env) error {
-	resetURL := fmt.Sprintf("%s/reset-password/confirm?token=%s", env.FrontendURL, token)
-
-	m := gomail.NewMessage()
-	m.SetHeader("From", env.Email)
-	m.SetHeader("To", email)
-	m.SetHeader("Subject", "Reset Your Password")
-	m.SetBody("text/html", fmt.Sprintf(
-		`<p>You requested a password reset. Click the link below to reset your password:</p>
-		<p><a href='%s'>Reset Password</a></p>
-		<p>If you did not request this, please ignore this email.</p>`,
-		resetURL,
-	))
-
-	d := gomail.NewDialer(
-		"smtp.hostinger.com",
-		587,
-		env.Email,
-		env.Password,
+func SendResetPasswordEmail(
+	ctx context.Context,
+	mailer mail.Mailer,
+	email, locale, token string,
+	env *config.Env,
+) error {
+	resetURL := fmt.Sprintf(
+		"%s/reset-password/confirm?token=%s",
+		env.FrontendURL,
+		url.QueryEscape(token),
 	)
-	d.SSL = false
 
-	d.TLSConfig = &tls.Config{InsecureSkipVerify: true} // sometimes needed for self-signed certs
-
-	if err := d.DialAndSend(m); err != nil {
-		log.Println("SMTP Connection Error:", err)
+	msg, err := mail.Compose(email, locale, mail.TemplateResetPassword, mail.ResetPasswordData{
+		Layout:       mail.Layout{SiteName: env.SiteName},
+		ResetURL:     resetURL,
+		ExpiresInMin: env.PasswordResetExpInMin,
+	})
+	if err != nil {
 		return err
 	}
-
-	return nil
+	return mailer.Send(ctx, msg)
 }
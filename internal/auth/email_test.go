@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sharon-xa/high-api/internal/config"
+	"github.com/sharon-xa/high-api/internal/mail"
+)
+
+var testEnv = &config.Env{
+	SiteName:              "High",
+	FrontendURL:           "https://high.example",
+	OtpExpMin:             10,
+	PasswordResetExpInMin: 30,
+}
+
+func TestSendVerificationEmail(t *testing.T) {
+	recorder := mail.NewRecorder()
+
+	err := SendVerificationEmail(
+		context.Background(),
+		recorder,
+		"reader@example.com",
+		"en",
+		"482913",
+		testEnv,
+	)
+	if err != nil {
+		t.Fatalf("SendVerificationEmail() error = %v", err)
+	}
+
+	messages := recorder.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("sent %d emails, want 1", len(messages))
+	}
+	msg := messages[0]
+	if msg.To != "reader@example.com" {
+		t.Errorf("To = %q, want reader@example.com", msg.To)
+	}
+	if msg.Subject != "High account verification" {
+		t.Errorf("Subject = %q", msg.Subject)
+	}
+	for name, body := range map[string]string{"HTML": msg.HTML, "Text": msg.Text} {
+		if !strings.Contains(body, "482913") {
+			t.Errorf("%s body is missing the OTP", name)
+		}
+	}
+}
+
+func TestSendResetPasswordEmail(t *testing.T) {
+	recorder := mail.NewRecorder()
+
+	err := SendResetPasswordEmail(
+		context.Background(),
+		recorder,
+		"reader@example.com",
+		"ar",
+		"a+b/c",
+		testEnv,
+	)
+	if err != nil {
+		t.Fatalf("SendResetPasswordEmail() error = %v", err)
+	}
+
+	messages := recorder.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("sent %d emails, want 1", len(messages))
+	}
+	msg := messages[0]
+	if msg.To != "reader@example.com" {
+		t.Errorf("To = %q, want reader@example.com", msg.To)
+	}
+
+	// The token is escaped in the link.
+	resetURL := "https://high.example/reset-password/confirm?token=a%2Bb%2Fc"
+	if !strings.Contains(msg.Text, resetURL) {
+		t.Errorf("Text body is missing the reset link %s", resetURL)
+	}
+	if !strings.Contains(msg.HTML, resetURL) {
+		t.Errorf("HTML body is missing the reset link %s", resetURL)
+	}
+
+	english := mail.NewRecorder()
+	err = SendResetPasswordEmail(
+		context.Background(),
+		english,
+		"reader@example.com",
+		"en",
+		"a+b/c",
+		testEnv,
+	)
+	if err != nil {
+		t.Fatalf("SendResetPasswordEmail() error = %v", err)
+	}
+	if english.Messages()[0].Subject == msg.Subject {
+		t.Errorf("the Arabic email has the English subject %q", msg.Subject)
+	}
+}
@@ -79,3 +79,85 @@ func ParseAccessToken(token, accessTokenSecret string) (claims *AccessClaims, er
 
 	return claims, nil
 }
+
+const unsubscribeAudience = "unsubscribe"
+
+// GenerateUnsubscribeToken signs the token of an unsubscribe link. It
+// doesn't expire, so links in old emails keep working.
+func GenerateUnsubscribeToken(userID, kind, tokenSecret string) (string, error) {
+	claims := &UnsubscribeClaims{
+		Kind: kind,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:  userID,
+			Audience: jwt.ClaimStrings{unsubscribeAudience},
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(tokenSecret))
+}
+
+func ParseUnsubscribeToken(token, tokenSecret string) (*UnsubscribeClaims, error) {
+	parsedToken, err := jwt.ParseWithClaims(
+		token,
+		&UnsubscribeClaims{},
+		func(t *jwt.Token) (any, error) {
+			return []byte(tokenSecret), nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithAudience(unsubscribeAudience),
+	)
+	if err != nil || !parsedToken.Valid {
+		return nil, utils.ErrInvalidToken
+	}
+
+	claims, ok := parsedToken.Claims.(*UnsubscribeClaims)
+	if !ok {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+
+	return claims, nil
+}
+
+const streamAudience = "notifications-stream"
+
+// streamTokenLifetime only has to cover opening the stream, since the
+// token travels in the URL where it may be logged.
+const streamTokenLifetime = time.Minute
+
+// GenerateStreamToken signs the token that opens the notification stream.
+// Browsers' EventSource can't send an Authorization header, so the token
+// goes in the query string instead of the access token.
+func GenerateStreamToken(userID, tokenSecret string) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Subject:   userID,
+		Audience:  jwt.ClaimStrings{streamAudience},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(streamTokenLifetime)),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(tokenSecret))
+}
+
+func ParseStreamToken(token, tokenSecret string) (*jwt.RegisteredClaims, error) {
+	parsedToken, err := jwt.ParseWithClaims(
+		token,
+		&jwt.RegisteredClaims{},
+		func(t *jwt.Token) (any, error) {
+			return []byte(tokenSecret), nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithAudience(streamAudience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || !parsedToken.Valid {
+		return nil, utils.ErrInvalidToken
+	}
+
+	claims, ok := parsedToken.Claims.(*jwt.RegisteredClaims)
+	if !ok {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+
+	return claims, nil
+}
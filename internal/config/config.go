@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/spf13/viper"
 )
@@ -16,12 +17,22 @@ type Env struct {
 	PasswordResetExpInMin int    `mapstructure:"PASSWORD_RESET_EXP_IN_MIN"`
 	APIDomain             string `mapstructure:"API_DOMAIN"`
 	UserMinAge            int    `mapstructure:"USER_MIN_AGE"`
+	SiteName              string `mapstructure:"SITE_NAME"`
+	APIURL                string `mapstructure:"API_URL"`
 
 	// Email
 	Email       string `mapstructure:"EMAIL"`
 	Password    string `mapstructure:"PASSWORD"`
 	FrontendURL string `mapstructure:"FRONTEND_PASS_RESET_URL"`
 
+	// Mail delivery, MAIL_DRIVER is one of smtp, file or memory
+	MailDriver   string `mapstructure:"MAIL_DRIVER"`
+	MailDir      string `mapstructure:"MAIL_DIR"`
+	SMTPHost     string `mapstructure:"SMTP_HOST"`
+	SMTPPort     int    `mapstructure:"SMTP_PORT"`
+	SMTPTLS      string `mapstructure:"SMTP_TLS"`
+	SMTPUsername string `mapstructure:"SMTP_USERNAME"`
+
 	// DB
 	DBHost     string `mapstructure:"DB_HOST"`
 	DBPort     string `mapstructure:"DB_PORT"`
@@ -75,6 +86,30 @@ func NewEnv() *Env {
 		env.DBTimeZone,
 	)
 
+	if env.SiteName == "" {
+		env.SiteName = "High"
+	}
+	if env.MailDriver == "" {
+		env.MailDriver = "smtp"
+	}
+	if env.MailDir == "" {
+		env.MailDir = "tmp/mail"
+	}
+	if env.SMTPHost == "" {
+		env.SMTPHost = "smtp.hostinger.com"
+	}
+	if env.SMTPPort == 0 {
+		env.SMTPPort = 465
+	}
+	if env.SMTPTLS == "" {
+		env.SMTPTLS = "tls"
+	}
+	if env.SMTPUsername == "" {
+		env.SMTPUsername = env.Email
+	}
+	env.FrontendURL = strings.TrimRight(env.FrontendURL, "/")
+	env.APIURL = strings.TrimRight(env.APIURL, "/")
+
 	if env.Environment == "dev" {
 		log.Println("The App is running in development mode")
 	}
@@ -0,0 +1,82 @@
+package content
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// Formats a post's content can be written in.
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatPlain    = "plain"
+)
+
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	// Raw HTML is let through here and cleaned up by the sanitizer.
+	goldmark.WithRendererOptions(goldmarkhtml.WithUnsafe()),
+)
+
+// policy is an allowlist of user generated content elements. It drops
+// scripts, event handler attributes and javascript: URLs.
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").
+		Matching(regexp.MustCompile(`^language-[\w+-]+$`)).
+		OnElements("code")
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}
+
+// ValidFormat reports whether format is one of the supported formats.
+func ValidFormat(format string) bool {
+	switch format {
+	case FormatMarkdown, FormatHTML, FormatPlain:
+		return true
+	}
+	return false
+}
+
+// Render converts source written in format to sanitized HTML.
+func Render(source, format string) (string, error) {
+	switch format {
+	case FormatMarkdown:
+		var buf bytes.Buffer
+		if err := markdown.Convert([]byte(source), &buf); err != nil {
+			return "", err
+		}
+		return policy.Sanitize(buf.String()), nil
+	case FormatHTML:
+		return policy.Sanitize(source), nil
+	default:
+		return renderPlain(source), nil
+	}
+}
+
+// renderPlain escapes source, turning blank line separated blocks into
+// paragraphs and single newlines into line breaks.
+func renderPlain(source string) string {
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+
+	var sb strings.Builder
+	for block := range strings.SplitSeq(source, "\n\n") {
+		block = strings.TrimSpace(block)
+		if block == "" {
+			continue
+		}
+		sb.WriteString("<p>")
+		sb.WriteString(strings.ReplaceAll(html.EscapeString(block), "\n", "<br>"))
+		sb.WriteString("</p>\n")
+	}
+	return sb.String()
+}
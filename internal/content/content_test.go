@@ -0,0 +1,146 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		format string
+		want   string
+	}{
+		// Markdown
+		{
+			name:   "markdown",
+			source: "# Title\n\nSome *text*.",
+			format: FormatMarkdown,
+			want:   "<h1>Title</h1>\n<p>Some <em>text</em>.</p>\n",
+		},
+		{
+			name:   "markdown script tag",
+			source: "Hi\n\n<script>alert(1)</script>",
+			format: FormatMarkdown,
+			want:   "<p>Hi</p>\n",
+		},
+		{
+			name:   "markdown raw html",
+			source: "<div onclick=\"alert(1)\"><b>bold</b></div>",
+			format: FormatMarkdown,
+			want:   "<div><b>bold</b></div>",
+		},
+		{
+			name:   "markdown javascript link",
+			source: "[click](javascript:alert(1))",
+			format: FormatMarkdown,
+			want:   "<p>click</p>\n",
+		},
+		{
+			name:   "markdown external link",
+			source: "[site](https://example.com)",
+			format: FormatMarkdown,
+			want: `<p><a href="https://example.com" rel="nofollow noopener" ` +
+				`target="_blank">site</a></p>` + "\n",
+		},
+		{
+			name:   "markdown code block language",
+			source: "```go\nx := 1\n```",
+			format: FormatMarkdown,
+			want:   "<pre><code class=\"language-go\">x := 1\n</code></pre>\n",
+		},
+		{
+			name:   "markdown text is escaped",
+			source: "1 < 2 & 3 > 2",
+			format: FormatMarkdown,
+			want:   "<p>1 &lt; 2 &amp; 3 &gt; 2</p>\n",
+		},
+
+		// HTML
+		{
+			name:   "html",
+			source: "<p>Some <em>text</em>.</p>",
+			format: FormatHTML,
+			want:   "<p>Some <em>text</em>.</p>",
+		},
+		{
+			name:   "html script tag",
+			source: "<p>Hi</p><script>alert(1)</script>",
+			format: FormatHTML,
+			want:   "<p>Hi</p>",
+		},
+		{
+			name:   "html event handler",
+			source: `<img src="https://example.com/a.png" onerror="alert(1)">`,
+			format: FormatHTML,
+			want:   `<img src="https://example.com/a.png">`,
+		},
+		{
+			name:   "html javascript url",
+			source: `<a href="javascript:alert(1)">click</a>`,
+			format: FormatHTML,
+			want:   "click",
+		},
+		{
+			name:   "html unknown class",
+			source: `<code class="evil">x</code>`,
+			format: FormatHTML,
+			want:   "<code>x</code>",
+		},
+		{
+			name:   "html text is escaped",
+			source: "1 < 2 & 3",
+			format: FormatHTML,
+			want:   "1 &lt; 2 &amp; 3",
+		},
+
+		// Plain text
+		{
+			name:   "plain paragraphs and line breaks",
+			source: "one\ntwo\r\n\r\n\n\nthree",
+			format: FormatPlain,
+			want:   "<p>one<br>two</p>\n<p>three</p>\n",
+		},
+		{
+			name:   "plain html is escaped",
+			source: `<script>alert("x")</script> & <b>`,
+			format: FormatPlain,
+			want:   "<p>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; &amp; &lt;b&gt;</p>\n",
+		},
+		{
+			name:   "plain empty",
+			source: " \n\n \n",
+			format: FormatPlain,
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.source, tt.format)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+			if strings.Contains(strings.ToLower(got), "javascript:") {
+				t.Errorf("Render() = %q, kept a javascript: URL", got)
+			}
+		})
+	}
+}
+
+func TestValidFormat(t *testing.T) {
+	for _, format := range []string{FormatMarkdown, FormatHTML, FormatPlain} {
+		if !ValidFormat(format) {
+			t.Errorf("ValidFormat(%q) = false, want true", format)
+		}
+	}
+	for _, format := range []string{"", "Markdown", "rst"} {
+		if ValidFormat(format) {
+			t.Errorf("ValidFormat(%q) = true, want false", format)
+		}
+	}
+}
@@ -34,18 +34,69 @@ func (s *service) DB() *gorm.DB {
 }
 
 func (s *service) AutoMigrate() {
-	err := s.db.AutoMigrate(
+	if err := Migrate(s.db); err != nil {
+		log.Fatalf("AutoMigrate failed: %v", err)
+	}
+}
+
+// Models lists every model with a table, in the order they are migrated.
+func Models() []any {
+	return []any{
 		&User{},
 		&AccountVerificationOTP{},
 		&PasswordResetToken{},
 		&Post{},
+		&PostRevision{},
+		&PostSlug{},
 		&Comment{},
+		&Reaction{},
+		&ReactionCount{},
+		&Follow{},
+		&TagFollow{},
+		&Report{},
+		&Ban{},
+		&AuditEvent{},
+		&Notification{},
+		&NotificationPreference{},
+		&OutboundEmail{},
 		&Tag{},
 		&PostTag{},
 		&Category{},
 		&RefreshToken{},
-	)
+	}
+}
+
+// Migrate creates or updates the tables of every model in db.
+func Migrate(db *gorm.DB) error {
+	err := db.AutoMigrate(Models()...)
 	if err != nil {
-		log.Fatalf("AutoMigrate failed: %v", err)
+		return err
+	}
+
+	// AutoMigrate can't create triggers, and the audit log must not be left
+	// writable when it creates the table before the migrations run.
+	for _, stmt := range auditEventTriggers {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+// auditEventTriggers make audit_events append-only, the same way the
+// add_audit_events migration does.
+var auditEventTriggers = []string{
+	`CREATE OR REPLACE FUNCTION audit_events_reject_change() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'audit events are append-only';
+	END
+	$$ LANGUAGE plpgsql`,
+	`DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events`,
+	`CREATE TRIGGER audit_events_append_only
+	BEFORE UPDATE OR DELETE ON audit_events
+	FOR EACH ROW EXECUTE FUNCTION audit_events_reject_change()`,
+	`DROP TRIGGER IF EXISTS audit_events_no_truncate ON audit_events`,
+	`CREATE TRIGGER audit_events_no_truncate
+	BEFORE TRUNCATE ON audit_events
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_events_reject_change()`,
 }
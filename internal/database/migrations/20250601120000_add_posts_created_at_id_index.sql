@@ -0,0 +1,6 @@
+-- +goose Up
+CREATE INDEX IF NOT EXISTS idx_posts_created_at_id
+ON posts (created_at DESC, id DESC);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_posts_created_at_id;
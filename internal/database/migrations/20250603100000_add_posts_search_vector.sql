@@ -0,0 +1,95 @@
+-- +goose Up
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS search_vector tsvector;
+
+-- +goose StatementBegin
+-- Title weighs more than tags, and tags more than content.
+CREATE OR REPLACE FUNCTION posts_build_search_vector(p_id BIGINT, p_title TEXT, p_content TEXT)
+RETURNS tsvector AS $$
+    SELECT setweight(to_tsvector('english', coalesce(p_title, '')), 'A')
+        || setweight(to_tsvector('english', coalesce((
+            SELECT string_agg(tags.name, ' ')
+            FROM post_tags
+            JOIN tags ON tags.id = post_tags.tag_id
+            WHERE post_tags.post_id = p_id
+        ), '')), 'B')
+        || setweight(to_tsvector('english', coalesce(p_content, '')), 'C');
+$$ LANGUAGE sql STABLE;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION posts_search_vector_on_post() RETURNS trigger AS $$
+BEGIN
+    NEW.search_vector := posts_build_search_vector(NEW.id, NEW.title, NEW.content);
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION posts_search_vector_on_post_tag() RETURNS trigger AS $$
+DECLARE
+    affected_post_id BIGINT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        affected_post_id := OLD.post_id;
+    ELSE
+        affected_post_id := NEW.post_id;
+    END IF;
+
+    UPDATE posts
+    SET search_vector = posts_build_search_vector(id, title, content)
+    WHERE id = affected_post_id;
+
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION posts_search_vector_on_tag() RETURNS trigger AS $$
+BEGIN
+    UPDATE posts
+    SET search_vector = posts_build_search_vector(id, title, content)
+    WHERE id IN (SELECT post_id FROM post_tags WHERE tag_id = NEW.id);
+
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+DROP TRIGGER IF EXISTS posts_search_vector_update ON posts;
+CREATE TRIGGER posts_search_vector_update
+BEFORE INSERT OR UPDATE OF title, content ON posts
+FOR EACH ROW EXECUTE FUNCTION posts_search_vector_on_post();
+
+DROP TRIGGER IF EXISTS post_tags_search_vector_update ON post_tags;
+CREATE TRIGGER post_tags_search_vector_update
+AFTER INSERT OR DELETE ON post_tags
+FOR EACH ROW EXECUTE FUNCTION posts_search_vector_on_post_tag();
+
+DROP TRIGGER IF EXISTS tags_search_vector_update ON tags;
+CREATE TRIGGER tags_search_vector_update
+AFTER UPDATE OF name ON tags
+FOR EACH ROW EXECUTE FUNCTION posts_search_vector_on_tag();
+
+UPDATE posts
+SET search_vector = posts_build_search_vector(id, title, content);
+
+CREATE INDEX IF NOT EXISTS idx_posts_search_vector
+ON posts USING GIN (search_vector);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_posts_search_vector;
+
+DROP TRIGGER IF EXISTS tags_search_vector_update ON tags;
+DROP TRIGGER IF EXISTS post_tags_search_vector_update ON post_tags;
+DROP TRIGGER IF EXISTS posts_search_vector_update ON posts;
+
+DROP FUNCTION IF EXISTS posts_search_vector_on_tag();
+DROP FUNCTION IF EXISTS posts_search_vector_on_post_tag();
+DROP FUNCTION IF EXISTS posts_search_vector_on_post();
+DROP FUNCTION IF EXISTS posts_build_search_vector(BIGINT, TEXT, TEXT);
+
+ALTER TABLE posts
+DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,32 @@
+-- +goose Up
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'published';
+
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS publish_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE posts
+SET publish_at = created_at
+WHERE publish_at IS NULL AND status = 'published';
+
+-- Public listings are ordered by publish time now.
+DROP INDEX IF EXISTS idx_posts_created_at_id;
+
+CREATE INDEX IF NOT EXISTS idx_posts_status ON posts (status);
+
+CREATE INDEX IF NOT EXISTS idx_posts_published
+ON posts (publish_at DESC, id DESC)
+WHERE status = 'published';
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_posts_published;
+DROP INDEX IF EXISTS idx_posts_status;
+
+CREATE INDEX IF NOT EXISTS idx_posts_created_at_id
+ON posts (created_at DESC, id DESC);
+
+ALTER TABLE posts
+DROP COLUMN IF EXISTS publish_at;
+
+ALTER TABLE posts
+DROP COLUMN IF EXISTS status;
@@ -0,0 +1,24 @@
+-- +goose Up
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS slug TEXT;
+
+-- Existing posts get "<title>-<id>" slugs, the id suffix keeps them unique.
+UPDATE posts
+SET slug = coalesce(
+    nullif(trim(BOTH '-' FROM left(
+        lower(regexp_replace(title, '[^a-zA-Z0-9]+', '-', 'g')),
+        70
+    )), '') || '-',
+    'post-'
+) || id
+WHERE slug IS NULL OR slug = '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_slug
+ON posts (slug)
+WHERE slug <> '';
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_posts_slug;
+
+ALTER TABLE posts
+DROP COLUMN IF EXISTS slug;
@@ -0,0 +1,25 @@
+-- +goose Up
+-- Posts written so far were rendered as HTML by the frontend.
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS content_format TEXT NOT NULL DEFAULT 'html';
+
+ALTER TABLE posts
+ALTER COLUMN content_format SET DEFAULT 'markdown';
+
+-- content_html stays empty for older posts until they are next edited, the
+-- API renders them on every request meanwhile.
+ALTER TABLE posts
+ADD COLUMN IF NOT EXISTS content_html TEXT;
+
+ALTER TABLE post_revisions
+ADD COLUMN IF NOT EXISTS content_format TEXT;
+
+-- +goose Down
+ALTER TABLE post_revisions
+DROP COLUMN IF EXISTS content_format;
+
+ALTER TABLE posts
+DROP COLUMN IF EXISTS content_html;
+
+ALTER TABLE posts
+DROP COLUMN IF EXISTS content_format;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- Prefix search on names and emails in the admin user list.
+CREATE INDEX IF NOT EXISTS idx_users_lower_name ON users (lower(name) text_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_users_lower_email ON users (lower(email) text_pattern_ops);
+
+-- Per user post and comment counts.
+CREATE INDEX IF NOT EXISTS idx_posts_user_id ON posts (user_id);
+CREATE INDEX IF NOT EXISTS idx_comments_user_id ON comments (user_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_comments_user_id;
+DROP INDEX IF EXISTS idx_posts_user_id;
+DROP INDEX IF EXISTS idx_users_lower_email;
+DROP INDEX IF EXISTS idx_users_lower_name;
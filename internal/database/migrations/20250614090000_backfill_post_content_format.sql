@@ -0,0 +1,12 @@
+-- +goose Up
+-- AutoMigrate may have added content_format before the
+-- add_post_content_format migration ran, giving posts written so far the
+-- 'markdown' default. Posts without content_html predate the column and were
+-- rendered as HTML by the frontend.
+UPDATE posts
+SET content_format = 'html'
+WHERE content_html IS NULL;
+
+-- +goose Down
+-- The formats the backfill replaced aren't known anymore.
+SELECT 1;
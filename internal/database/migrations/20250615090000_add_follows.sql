@@ -0,0 +1,31 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS follows (
+    follower_id BIGINT NOT NULL,
+    followee_id BIGINT NOT NULL,
+    created_at TIMESTAMPTZ,
+    PRIMARY KEY (follower_id, followee_id),
+    CONSTRAINT fk_follows_follower FOREIGN KEY (follower_id)
+        REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_follows_followee FOREIGN KEY (followee_id)
+        REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- Followers of a user, the primary key covers whom a user follows.
+CREATE INDEX IF NOT EXISTS idx_follows_followee_id ON follows (followee_id);
+
+CREATE TABLE IF NOT EXISTS tag_follows (
+    user_id BIGINT NOT NULL,
+    tag_id BIGINT NOT NULL,
+    created_at TIMESTAMPTZ,
+    PRIMARY KEY (user_id, tag_id),
+    CONSTRAINT fk_tag_follows_user FOREIGN KEY (user_id)
+        REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_tag_follows_tag FOREIGN KEY (tag_id)
+        REFERENCES tags (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_tag_follows_tag_id ON tag_follows (tag_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS tag_follows;
+DROP TABLE IF EXISTS follows;
@@ -0,0 +1,31 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS notifications (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    actor_id BIGINT,
+    type TEXT NOT NULL,
+    target_type TEXT NOT NULL,
+    target_id BIGINT,
+    post_id BIGINT,
+    message TEXT,
+    read_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ,
+    CONSTRAINT fk_notifications_user FOREIGN KEY (user_id)
+        REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_notifications_actor FOREIGN KEY (actor_id)
+        REFERENCES users (id) ON DELETE SET NULL
+);
+
+-- A user's notifications, newest first.
+CREATE INDEX IF NOT EXISTS idx_notifications_user_created
+ON notifications (user_id, created_at);
+
+-- Unread counts.
+CREATE INDEX IF NOT EXISTS idx_notifications_unread
+ON notifications (user_id)
+WHERE read_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_notifications_actor_id ON notifications (actor_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS notifications;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- Users without a row get the defaults of the API.
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id BIGINT PRIMARY KEY,
+    comment TEXT NOT NULL,
+    reply TEXT NOT NULL,
+    follow TEXT NOT NULL,
+    weekly_digest BOOLEAN NOT NULL,
+    digest_sent_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    CONSTRAINT fk_notification_preferences_user FOREIGN KEY (user_id)
+        REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS notification_preferences;
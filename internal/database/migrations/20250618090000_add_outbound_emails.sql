@@ -0,0 +1,24 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS outbound_emails (
+    id BIGSERIAL PRIMARY KEY,
+    "to" TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    html TEXT NOT NULL,
+    text TEXT,
+    headers JSONB,
+    status TEXT NOT NULL,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    attempts BIGINT NOT NULL,
+    last_error TEXT,
+    sent_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+
+-- The outbox workers pick the next due email.
+CREATE INDEX IF NOT EXISTS idx_outbound_emails_due
+ON outbound_emails (status, next_attempt_at)
+WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE IF EXISTS outbound_emails;
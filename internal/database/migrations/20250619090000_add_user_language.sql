@@ -0,0 +1,8 @@
+-- +goose Up
+-- The locale of the emails a user gets.
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS language TEXT NOT NULL DEFAULT 'en';
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN IF EXISTS language;
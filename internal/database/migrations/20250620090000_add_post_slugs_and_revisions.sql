@@ -0,0 +1,39 @@
+-- +goose Up
+-- Slugs a post had before it was renamed, so that old links still resolve.
+CREATE TABLE IF NOT EXISTS post_slugs (
+    id BIGSERIAL PRIMARY KEY,
+    post_id BIGINT NOT NULL,
+    slug TEXT NOT NULL,
+    created_at TIMESTAMPTZ,
+    CONSTRAINT fk_post_slugs_post FOREIGN KEY (post_id)
+        REFERENCES posts (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_slugs_post_id ON post_slugs (post_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_post_slugs_slug ON post_slugs (slug);
+
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    post_id BIGINT NOT NULL,
+    number BIGINT NOT NULL,
+    editor_id BIGINT,
+    title TEXT,
+    content TEXT,
+    content_format TEXT,
+    category_id BIGINT,
+    tags TEXT,
+    created_at TIMESTAMPTZ,
+    CONSTRAINT fk_post_revisions_post FOREIGN KEY (post_id)
+        REFERENCES posts (id) ON DELETE CASCADE,
+    CONSTRAINT fk_post_revisions_editor FOREIGN KEY (editor_id)
+        REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_post_revisions_post_number
+ON post_revisions (post_id, number);
+
+CREATE INDEX IF NOT EXISTS idx_post_revisions_editor_id ON post_revisions (editor_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS post_revisions;
+DROP TABLE IF EXISTS post_slugs;
@@ -0,0 +1,36 @@
+-- +goose Up
+ALTER TABLE comments
+ADD COLUMN IF NOT EXISTS parent_id BIGINT,
+ADD COLUMN IF NOT EXISTS depth BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS tombstoned BOOLEAN NOT NULL DEFAULT false,
+ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'approved',
+ADD COLUMN IF NOT EXISTS flagged BOOLEAN NOT NULL DEFAULT false;
+
+-- Replies go away with the comment they answer.
+ALTER TABLE comments
+DROP CONSTRAINT IF EXISTS fk_comments_parent;
+
+ALTER TABLE comments
+ADD CONSTRAINT fk_comments_parent FOREIGN KEY (parent_id)
+    REFERENCES comments (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_comments_post_id ON comments (post_id);
+CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments (parent_id);
+CREATE INDEX IF NOT EXISTS idx_comments_status ON comments (status);
+CREATE INDEX IF NOT EXISTS idx_comments_flagged ON comments (flagged);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_comments_flagged;
+DROP INDEX IF EXISTS idx_comments_status;
+DROP INDEX IF EXISTS idx_comments_parent_id;
+DROP INDEX IF EXISTS idx_comments_post_id;
+
+ALTER TABLE comments
+DROP CONSTRAINT IF EXISTS fk_comments_parent;
+
+ALTER TABLE comments
+DROP COLUMN IF EXISTS flagged,
+DROP COLUMN IF EXISTS status,
+DROP COLUMN IF EXISTS tombstoned,
+DROP COLUMN IF EXISTS depth,
+DROP COLUMN IF EXISTS parent_id;
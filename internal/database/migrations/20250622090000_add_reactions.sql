@@ -0,0 +1,30 @@
+-- +goose Up
+-- Reactions point at posts and comments through target_type and target_id,
+-- so only the user gets a foreign key.
+CREATE TABLE IF NOT EXISTS reactions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    target_type TEXT NOT NULL,
+    target_id BIGINT NOT NULL,
+    type TEXT NOT NULL,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    CONSTRAINT fk_reactions_user FOREIGN KEY (user_id)
+        REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- One reaction per user and target.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reactions_user_target
+ON reactions (user_id, target_type, target_id);
+
+CREATE TABLE IF NOT EXISTS reaction_counts (
+    target_type TEXT NOT NULL,
+    target_id BIGINT NOT NULL,
+    type TEXT NOT NULL,
+    count BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (target_type, target_id, type)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS reaction_counts;
+DROP TABLE IF EXISTS reactions;
@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS reports (
+    id BIGSERIAL PRIMARY KEY,
+    reporter_id BIGINT NOT NULL,
+    target_type TEXT NOT NULL,
+    target_id BIGINT NOT NULL,
+    reason TEXT NOT NULL,
+    details TEXT,
+    status TEXT NOT NULL DEFAULT 'open',
+    assignee_id BIGINT,
+    action TEXT,
+    note TEXT,
+    resolver_id BIGINT,
+    resolved_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    CONSTRAINT fk_reports_reporter FOREIGN KEY (reporter_id)
+        REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_reports_assignee FOREIGN KEY (assignee_id)
+        REFERENCES users (id) ON DELETE SET NULL,
+    CONSTRAINT fk_reports_resolver FOREIGN KEY (resolver_id)
+        REFERENCES users (id) ON DELETE SET NULL
+);
+
+-- A user can only have one open report per target.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_open_reporter_target
+ON reports (reporter_id, target_type, target_id)
+WHERE status = 'open';
+
+CREATE INDEX IF NOT EXISTS idx_reports_target ON reports (target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_reports_status ON reports (status);
+CREATE INDEX IF NOT EXISTS idx_reports_assignee_id ON reports (assignee_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS reports;
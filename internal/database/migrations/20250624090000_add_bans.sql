@@ -0,0 +1,24 @@
+-- +goose Up
+-- users.banned mirrors whether a user has a ban in effect.
+CREATE TABLE IF NOT EXISTS bans (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    issuer_id BIGINT,
+    reason TEXT NOT NULL,
+    expires_at TIMESTAMPTZ,
+    lifted_at TIMESTAMPTZ,
+    lifter_id BIGINT,
+    created_at TIMESTAMPTZ,
+    CONSTRAINT fk_bans_user FOREIGN KEY (user_id)
+        REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_bans_issuer FOREIGN KEY (issuer_id)
+        REFERENCES users (id) ON DELETE SET NULL,
+    CONSTRAINT fk_bans_lifter FOREIGN KEY (lifter_id)
+        REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_bans_user_id ON bans (user_id);
+CREATE INDEX IF NOT EXISTS idx_bans_issuer_id ON bans (issuer_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS bans;
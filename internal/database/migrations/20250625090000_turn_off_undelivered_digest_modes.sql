@@ -0,0 +1,14 @@
+-- +goose Up
+-- Notifications in digest mode were never emailed to users with the weekly
+-- digest off. The API doesn't allow that combination anymore, and turns
+-- those notifications off when the digest goes off.
+UPDATE notification_preferences
+SET comment = CASE WHEN comment = 'digest' THEN 'off' ELSE comment END,
+    reply = CASE WHEN reply = 'digest' THEN 'off' ELSE reply END,
+    follow = CASE WHEN follow = 'digest' THEN 'off' ELSE follow END
+WHERE NOT weekly_digest
+    AND 'digest' IN (comment, reply, follow);
+
+-- +goose Down
+-- The modes the update replaced aren't known anymore.
+SELECT 1;
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"encoding/json"
 	"time"
 
 	"gorm.io/gorm"
@@ -18,6 +19,8 @@ type User struct {
 	Verified  bool      `gorm:"default:false"`
 	Banned    bool      `gorm:"default:false"`
 	Birthdate time.Time `gorm:"type:date"      json:"birthdate"`
+	// Language is the locale of the emails the user gets.
+	Language string `gorm:"not null;default:'en'"`
 
 	Posts                  []Post
 	Comments               []Comment
@@ -51,13 +54,35 @@ type RefreshToken struct {
 	UpdatedAt    time.Time
 }
 
+// Post statuses. Only published posts are visible to the public; scheduled
+// posts are flipped to published by the server's scheduler once PublishAt
+// has passed.
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+	PostStatusArchived  = "archived"
+)
+
 type Post struct {
 	gorm.Model
 	UserID     uint
 	CategoryID uint
 	Title      string
+	Slug       string `gorm:"uniqueIndex:idx_posts_slug,where:slug <> ''"`
 	Content    string
 	Image      string
+	Status     string `gorm:"default:'published';index"`
+	PublishAt  *time.Time
+
+	// ContentFormat is the markup Content is written in, ContentHTML caches
+	// it rendered and sanitized.
+	ContentFormat string `gorm:"default:'markdown'"`
+	ContentHTML   string
+
+	// SearchVector is maintained by a database trigger, see the
+	// add_posts_search_vector migration.
+	SearchVector string `gorm:"type:tsvector;->:false;<-:false" json:"-"`
 
 	User     User `gorm:"constraint:OnDelete:CASCADE;"`
 	Category Category
@@ -65,14 +90,66 @@ type Post struct {
 	Comments []Comment
 }
 
+// PostSlug is a slug a post used to have. Retired slugs are kept so that
+// old links keep resolving after the title changes.
+type PostSlug struct {
+	ID        uint   `gorm:"primaryKey"`
+	PostID    uint   `gorm:"not null;index"`
+	Slug      string `gorm:"not null;uniqueIndex"`
+	CreatedAt time.Time
+
+	Post Post `gorm:"constraint:OnDelete:CASCADE;"`
+}
+
+// PostRevision is an immutable snapshot of a post, one is recorded every
+// time the post is created or edited.
+type PostRevision struct {
+	ID            uint  `gorm:"primaryKey"`
+	PostID        uint  `gorm:"not null;uniqueIndex:idx_post_revisions_post_number"`
+	Number        uint  `gorm:"not null;uniqueIndex:idx_post_revisions_post_number"`
+	EditorID      *uint `gorm:"index"`
+	Title         string
+	Content       string
+	ContentFormat string
+	CategoryID    uint
+	Tags          string // comma separated tag names
+	CreatedAt     time.Time
+
+	Post   Post  `gorm:"constraint:OnDelete:CASCADE;"`
+	Editor *User `gorm:"constraint:OnDelete:SET NULL;"`
+}
+
+// MaxCommentDepth is how many levels a comment thread can have, top level
+// comments included.
+const MaxCommentDepth = 5
+
+// Comment moderation statuses. Pending comments are held for review and only
+// shown to their author until a moderator approves them.
+const (
+	CommentStatusApproved = "approved"
+	CommentStatusPending  = "pending"
+	CommentStatusRejected = "rejected"
+)
+
 type Comment struct {
 	gorm.Model
-	PostID  uint
-	UserID  uint
-	Content string
+	PostID   uint `gorm:"index"`
+	UserID   uint
+	ParentID *uint `gorm:"index"`
+	Depth    int   `gorm:"not null;default:0"`
+	Content  string
 
-	Post Post
-	User User
+	// Tombstoned comments were deleted while they had replies. They stay in
+	// the thread as "[deleted]" to keep the replies attached, their content
+	// is only kept for moderators.
+	Tombstoned bool `gorm:"not null;default:false"`
+
+	Status  string `gorm:"not null;default:'approved';index"`
+	Flagged bool   `gorm:"not null;default:false;index"`
+
+	Post   Post
+	User   User
+	Parent *Comment `gorm:"constraint:OnDelete:CASCADE;"`
 }
 
 type Tag struct {
@@ -92,3 +169,288 @@ type Category struct {
 
 	Posts []Post `json:"-"`
 }
+
+// Reaction targets.
+const (
+	ReactionTargetPost    = "post"
+	ReactionTargetComment = "comment"
+)
+
+// ReactionTypes is the fixed set of reactions users can leave.
+var ReactionTypes = []string{"like", "love", "laugh", "wow", "sad", "angry"}
+
+// Reaction is the one reaction a user left on a post or a comment.
+type Reaction struct {
+	ID         uint   `gorm:"primaryKey"`
+	UserID     uint   `gorm:"not null;uniqueIndex:idx_reactions_user_target"`
+	TargetType string `gorm:"not null;uniqueIndex:idx_reactions_user_target"`
+	TargetID   uint   `gorm:"not null;uniqueIndex:idx_reactions_user_target"`
+	Type       string `gorm:"not null"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+
+	User User `gorm:"constraint:OnDelete:CASCADE;"`
+}
+
+// ReactionCount is the number of reactions of one type a target has. It is
+// kept in step with the Reaction rows so counts never need to be computed
+// per request.
+type ReactionCount struct {
+	TargetType string `gorm:"primaryKey"`
+	TargetID   uint   `gorm:"primaryKey"`
+	Type       string `gorm:"primaryKey"`
+	Count      int64  `gorm:"not null;default:0"`
+}
+
+// Follow puts the posts of FolloweeID in the feed of FollowerID.
+type Follow struct {
+	FollowerID uint `gorm:"primaryKey"`
+	FolloweeID uint `gorm:"primaryKey;index"`
+	CreatedAt  time.Time
+
+	Follower User `gorm:"constraint:OnDelete:CASCADE;"`
+	Followee User `gorm:"constraint:OnDelete:CASCADE;"`
+}
+
+// TagFollow puts the posts tagged with TagID in the feed of UserID.
+type TagFollow struct {
+	UserID    uint `gorm:"primaryKey"`
+	TagID     uint `gorm:"primaryKey;index"`
+	CreatedAt time.Time
+
+	User User `gorm:"constraint:OnDelete:CASCADE;"`
+	Tag  Tag  `gorm:"constraint:OnDelete:CASCADE;"`
+}
+
+// Report targets.
+const (
+	ReportTargetPost    = "post"
+	ReportTargetComment = "comment"
+	ReportTargetUser    = "user"
+)
+
+// ReportReasons is the fixed set of reasons a report can give.
+var ReportReasons = []string{
+	"spam",
+	"harassment",
+	"hate",
+	"violence",
+	"nudity",
+	"misinformation",
+	"other",
+}
+
+const (
+	ReportStatusOpen     = "open"
+	ReportStatusResolved = "resolved"
+)
+
+// Actions moderators can take when resolving a report.
+const (
+	ReportActionDeleteContent = "delete_content"
+	ReportActionBanUser       = "ban_user"
+	ReportActionDismiss       = "dismiss"
+)
+
+// Report is a user's complaint about a post, a comment or a profile. A user
+// can only have one open report per target.
+type Report struct {
+	ID         uint   `gorm:"primaryKey"`
+	ReporterID uint   `gorm:"not null;uniqueIndex:idx_reports_open_reporter_target,where:status = 'open'"`
+	TargetType string `gorm:"not null;uniqueIndex:idx_reports_open_reporter_target,where:status = 'open';index:idx_reports_target"`
+	TargetID   uint   `gorm:"not null;uniqueIndex:idx_reports_open_reporter_target,where:status = 'open';index:idx_reports_target"`
+	Reason     string `gorm:"not null"`
+	Details    string
+	Status     string `gorm:"not null;default:'open';index"`
+	AssigneeID *uint  `gorm:"index"`
+	Action     string
+	Note       string
+	ResolverID *uint
+	ResolvedAt *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+
+	Reporter User  `gorm:"constraint:OnDelete:CASCADE;"`
+	Assignee *User `gorm:"constraint:OnDelete:SET NULL;"`
+	Resolver *User `gorm:"constraint:OnDelete:SET NULL;"`
+}
+
+// Ban keeps a user out of the site until ExpiresAt, or for good when it is
+// nil, unless it gets lifted earlier. User.Banned mirrors whether the user
+// has a ban in effect.
+type Ban struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"not null;index"`
+	IssuerID  *uint  `gorm:"index"`
+	Reason    string `gorm:"not null"`
+	ExpiresAt *time.Time
+	LiftedAt  *time.Time
+	LifterID  *uint
+	CreatedAt time.Time
+
+	User   User  `gorm:"constraint:OnDelete:CASCADE;"`
+	Issuer *User `gorm:"constraint:OnDelete:SET NULL;"`
+	Lifter *User `gorm:"constraint:OnDelete:SET NULL;"`
+}
+
+// Audit actions.
+const (
+	AuditActionUserBan         = "user.ban"
+	AuditActionUserUnban       = "user.unban"
+	AuditActionUserRole        = "user.role"
+	AuditActionPostDelete      = "post.delete"
+	AuditActionCommentDelete   = "comment.delete"
+	AuditActionCommentModerate = "comment.moderate"
+	AuditActionReportAssign    = "report.assign"
+	AuditActionReportResolve   = "report.resolve"
+	AuditActionCategoryCreate  = "category.create"
+	AuditActionCategoryUpdate  = "category.update"
+	AuditActionCategoryDelete  = "category.delete"
+	AuditActionTagUpdate       = "tag.update"
+	AuditActionTagDelete       = "tag.delete"
+	AuditActionDataExport      = "data.export"
+	AuditActionEmailRequeue    = "email.requeue"
+)
+
+// Audit target types.
+const (
+	AuditTargetUser     = "user"
+	AuditTargetPost     = "post"
+	AuditTargetComment  = "comment"
+	AuditTargetReport   = "report"
+	AuditTargetCategory = "category"
+	AuditTargetTag      = "tag"
+	AuditTargetExport   = "export"
+	AuditTargetEmail    = "email"
+)
+
+// AuditEvent records a privileged action along with snapshots of its target
+// before and after it. Events are append-only, the add_audit_events
+// migration makes the database reject changes to them. They don't reference
+// users so that they outlive the accounts they mention.
+type AuditEvent struct {
+	ID         uint            `gorm:"primaryKey"`
+	ActorID    uint            `gorm:"not null;index"`
+	ActorRole  string          `gorm:"not null"`
+	Action     string          `gorm:"not null;index"`
+	TargetType string          `gorm:"not null;index:idx_audit_events_target"`
+	TargetID   uint            `gorm:"index:idx_audit_events_target"`
+	Before     json.RawMessage `gorm:"type:jsonb"`
+	After      json.RawMessage `gorm:"type:jsonb"`
+	IP         string
+	CreatedAt  time.Time `gorm:"index"`
+}
+
+// Notification types.
+const (
+	// NotificationComment is a new comment on a post of the recipient.
+	NotificationComment = "comment"
+	// NotificationReply is a reply to a comment of the recipient.
+	NotificationReply = "reply"
+	// NotificationFollow is a new follower of the recipient.
+	NotificationFollow = "follow"
+	// NotificationModeration is a moderator acting on the recipient's
+	// content, Message says what they did.
+	NotificationModeration = "moderation"
+)
+
+// Notification targets.
+const (
+	NotificationTargetPost    = "post"
+	NotificationTargetComment = "comment"
+	NotificationTargetUser    = "user"
+)
+
+// Notification tells a user about something that happened to them. Target
+// is what it is about: a post, a comment or a user. PostID is set for
+// comments, so clients can link to them.
+type Notification struct {
+	ID         uint   `gorm:"primaryKey"`
+	UserID     uint   `gorm:"not null;index:idx_notifications_user_created,priority:1;index:idx_notifications_unread,where:read_at IS NULL"`
+	ActorID    *uint  `gorm:"index"`
+	Type       string `gorm:"not null"`
+	TargetType string `gorm:"not null"`
+	TargetID   uint
+	PostID     *uint
+	Message    string
+	ReadAt     *time.Time
+	CreatedAt  time.Time `gorm:"index:idx_notifications_user_created,priority:2"`
+
+	User  User  `gorm:"constraint:OnDelete:CASCADE;"`
+	Actor *User `gorm:"constraint:OnDelete:SET NULL;"`
+}
+
+// How notifications of a type are emailed: right away, in the weekly
+// digest, or not at all.
+const (
+	EmailModeImmediate = "immediate"
+	EmailModeDigest    = "digest"
+	EmailModeOff       = "off"
+)
+
+// NotificationPreference is how a user wants to hear about their
+// notifications by email. Users without one get the defaults of
+// DefaultNotificationPreference.
+type NotificationPreference struct {
+	UserID       uint   `gorm:"primaryKey"`
+	Comment      string `gorm:"not null"`
+	Reply        string `gorm:"not null"`
+	Follow       string `gorm:"not null"`
+	WeeklyDigest bool   `gorm:"not null"`
+	DigestSentAt *time.Time
+	UpdatedAt    time.Time
+
+	User User `gorm:"constraint:OnDelete:CASCADE;"`
+}
+
+func DefaultNotificationPreference(userID uint) NotificationPreference {
+	return NotificationPreference{
+		UserID:       userID,
+		Comment:      EmailModeImmediate,
+		Reply:        EmailModeImmediate,
+		Follow:       EmailModeDigest,
+		WeeklyDigest: true,
+	}
+}
+
+// EmailMode returns how notifications of notificationType are emailed.
+func (p NotificationPreference) EmailMode(notificationType string) string {
+	switch notificationType {
+	case NotificationComment:
+		return p.Comment
+	case NotificationReply:
+		return p.Reply
+	case NotificationFollow:
+		return p.Follow
+	}
+	return EmailModeOff
+}
+
+const (
+	OutboundEmailPending = "pending"
+	OutboundEmailSent    = "sent"
+	// OutboundEmailDead is an email given up on after too many failed
+	// attempts. Admins can requeue it.
+	OutboundEmailDead = "dead"
+)
+
+// OutboundEmail is an email waiting to be sent, or that was. Emails are
+// written along with what causes them, in the same transaction, and the
+// outbox workers send them afterwards.
+type OutboundEmail struct {
+	ID      uint   `gorm:"primaryKey"`
+	To      string `gorm:"not null"`
+	Subject string `gorm:"not null"`
+	HTML    string `gorm:"not null"`
+	Text    string
+	Headers json.RawMessage `gorm:"type:jsonb"`
+	Status  string          `gorm:"not null;index:idx_outbound_emails_due,where:status = 'pending'"`
+	// NextAttemptAt is when a pending email is due. Sending pushes it back
+	// so that emails whose sender died get picked up again.
+	NextAttemptAt time.Time `gorm:"not null;index:idx_outbound_emails_due,where:status = 'pending'"`
+	Attempts      int       `gorm:"not null"`
+	LastError     string
+	SentAt        *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
@@ -0,0 +1,63 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// Formats an export can be written in.
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Writer writes a table one row at a time, so exports never have to be
+// held in memory. Close must be called once every row is written.
+type Writer interface {
+	Write(row []string) error
+	Close() error
+}
+
+// ContentType returns the media type of format.
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// New returns a writer producing format on w. sheet names the worksheet of
+// XLSX exports.
+func New(w io.Writer, format, sheet string) Writer {
+	if format == FormatXLSX {
+		return newXLSX(w, sheet)
+	}
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (cw *csvWriter) Write(row []string) error {
+	safe := make([]string, len(row))
+	for i, cell := range row {
+		safe[i] = neutralizeFormula(cell)
+	}
+	return cw.w.Write(safe)
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// neutralizeFormula keeps spreadsheet applications from evaluating cells
+// that look like formulas when a CSV export is opened.
+func neutralizeFormula(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
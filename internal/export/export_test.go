@@ -0,0 +1,56 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNeutralizeFormula(t *testing.T) {
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{"", ""},
+		{"plain", "plain"},
+		{"42", "42"},
+		{"=SUM(A1:A2)", "'=SUM(A1:A2)"},
+		{"+1", "'+1"},
+		{"-1", "'-1"},
+		{"@cmd", "'@cmd"},
+		{"\tindented", "'\tindented"},
+		{"\rreturn", "'\rreturn"},
+		{"a=b", "a=b"},
+	}
+
+	for _, tt := range tests {
+		if got := neutralizeFormula(tt.cell); got != tt.want {
+			t.Errorf("neutralizeFormula(%q) = %q, want %q", tt.cell, got, tt.want)
+		}
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := New(&buf, FormatCSV, "users")
+
+	rows := [][]string{
+		{"id", "name", "bio"},
+		{"1", "=HYPERLINK(\"http://evil\")", "line one\nline two"},
+		{"2", "Ann, Jr.", ""},
+	}
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	want := "id,name,bio\n" +
+		"1,\"'=HYPERLINK(\"\"http://evil\"\")\",\"line one\nline two\"\n" +
+		"2,\"Ann, Jr.\",\n"
+	if got := buf.String(); got != want {
+		t.Errorf("CSV output =\n%s\nwant\n%s", got, want)
+	}
+}
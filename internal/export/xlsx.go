@@ -0,0 +1,160 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The parts of a workbook with a single worksheet, everything but the
+// worksheet itself is fixed.
+const (
+	contentTypesXML = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`
+
+	rootRelsXML = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+
+	workbookRelsXML = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`
+
+	workbookXML = xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>` +
+		`</workbook>`
+
+	sheetStart = xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	sheetEnd   = `</sheetData></worksheet>`
+)
+
+// maxSheetNameLen is the longest worksheet name Excel accepts.
+const maxSheetNameLen = 31
+
+// xlsxWriter streams a workbook. Rows are written as inline strings straight
+// into the worksheet entry of the zip archive, so no shared strings table
+// has to be built up front.
+type xlsxWriter struct {
+	zw        *zip.Writer
+	sheet     *bufio.Writer
+	sheetName string
+	rowNum    int
+	err       error
+}
+
+func newXLSX(w io.Writer, sheet string) *xlsxWriter {
+	return &xlsxWriter{zw: zip.NewWriter(w), sheetName: sheet}
+}
+
+// start writes the fixed parts of the workbook and opens the worksheet.
+func (xw *xlsxWriter) start() error {
+	sheetName := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, xw.sheetName)
+	if sheetName == "" {
+		sheetName = "Sheet1"
+	}
+	if runes := []rune(sheetName); len(runes) > maxSheetNameLen {
+		sheetName = string(runes[:maxSheetNameLen])
+	}
+
+	var escapedName strings.Builder
+	if err := xml.EscapeText(&escapedName, []byte(sheetName)); err != nil {
+		return err
+	}
+
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", contentTypesXML},
+		{"_rels/.rels", rootRelsXML},
+		{"xl/_rels/workbook.xml.rels", workbookRelsXML},
+		{"xl/workbook.xml", strings.Replace(workbookXML, "%s", escapedName.String(), 1)},
+	}
+	for _, part := range parts {
+		f, err := xw.zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.body); err != nil {
+			return err
+		}
+	}
+
+	f, err := xw.zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	xw.sheet = bufio.NewWriter(f)
+	_, err = xw.sheet.WriteString(sheetStart)
+	return err
+}
+
+func (xw *xlsxWriter) Write(row []string) error {
+	if xw.err != nil {
+		return xw.err
+	}
+	if xw.sheet == nil {
+		if xw.err = xw.start(); xw.err != nil {
+			return xw.err
+		}
+	}
+
+	xw.rowNum++
+	xw.sheet.WriteString(`<row r="`)
+	xw.sheet.WriteString(strconv.Itoa(xw.rowNum))
+	xw.sheet.WriteString(`">`)
+	for i, cell := range row {
+		if cell == "" {
+			continue
+		}
+		xw.sheet.WriteString(`<c r="`)
+		xw.sheet.WriteString(columnName(i))
+		xw.sheet.WriteString(strconv.Itoa(xw.rowNum))
+		xw.sheet.WriteString(`" t="inlineStr"><is><t xml:space="preserve">`)
+		if xw.err = xml.EscapeText(xw.sheet, []byte(cell)); xw.err != nil {
+			return xw.err
+		}
+		xw.sheet.WriteString(`</t></is></c>`)
+	}
+	_, xw.err = xw.sheet.WriteString(`</row>`)
+	return xw.err
+}
+
+func (xw *xlsxWriter) Close() error {
+	if xw.err != nil {
+		return xw.err
+	}
+	if xw.sheet == nil {
+		if err := xw.start(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := xw.sheet.WriteString(sheetEnd); err != nil {
+		return err
+	}
+	if err := xw.sheet.Flush(); err != nil {
+		return err
+	}
+	return xw.zw.Close()
+}
+
+// columnName turns a 0-based column index into its letters: A, B, ..., Z,
+// AA, AB, ...
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
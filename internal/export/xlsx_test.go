@@ -0,0 +1,159 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readXLSX unzips a workbook written by xlsxWriter, returning its parts.
+func readXLSX(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("the workbook isn't a valid zip: %v", err)
+	}
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[f.Name] = string(body)
+	}
+	return parts
+}
+
+type sheetXML struct {
+	Rows []struct {
+		R     string `xml:"r,attr"`
+		Cells []struct {
+			R    string `xml:"r,attr"`
+			Text string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func TestXLSXWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := New(&buf, FormatXLSX, "users")
+
+	rows := [][]string{
+		{"id", "name", "bio"},
+		{"1", "<b>Tom & Jerry</b>", ""},
+		{"2", "=1+1", "  spaced  "},
+	}
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	parts := readXLSX(t, buf.Bytes())
+	for _, name := range []string{
+		"[Content_Types].xml",
+		"_rels/.rels",
+		"xl/_rels/workbook.xml.rels",
+		"xl/workbook.xml",
+		"xl/worksheets/sheet1.xml",
+	} {
+		body, ok := parts[name]
+		if !ok {
+			t.Errorf("the workbook has no %s", name)
+			continue
+		}
+		if err := xml.Unmarshal([]byte(body), new(struct{})); err != nil {
+			t.Errorf("%s isn't well-formed XML: %v", name, err)
+		}
+	}
+
+	var sheet sheetXML
+	if err := xml.Unmarshal([]byte(parts["xl/worksheets/sheet1.xml"]), &sheet); err != nil {
+		t.Fatal(err)
+	}
+
+	// Empty cells are left out, and cells are kept as text so formulas are
+	// never evaluated.
+	want := [][2]string{
+		{"A1", "id"}, {"B1", "name"}, {"C1", "bio"},
+		{"A2", "1"}, {"B2", "<b>Tom & Jerry</b>"},
+		{"A3", "2"}, {"B3", "=1+1"}, {"C3", "  spaced  "},
+	}
+	var got [][2]string
+	for _, row := range sheet.Rows {
+		for _, cell := range row.Cells {
+			got = append(got, [2]string{cell.R, cell.Text})
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cells = %v, want %v", got, want)
+	}
+}
+
+func TestXLSXWriterEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := New(&buf, FormatXLSX, "comments").Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	parts := readXLSX(t, buf.Bytes())
+	if _, ok := parts["xl/worksheets/sheet1.xml"]; !ok {
+		t.Error("an empty workbook has no worksheet")
+	}
+}
+
+func TestXLSXSheetName(t *testing.T) {
+	tests := []struct {
+		sheet string
+		want  string
+	}{
+		{"users", `name="users"`},
+		{"", `name="Sheet1"`},
+		{"a/b:c", `name="a_b_c"`},
+		{"R&D", `name="R&amp;D"`},
+		{strings.Repeat("x", 40), `name="` + strings.Repeat("x", maxSheetNameLen) + `"`},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := New(&buf, FormatXLSX, tt.sheet).Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+		workbook := readXLSX(t, buf.Bytes())["xl/workbook.xml"]
+		if !strings.Contains(workbook, tt.want) {
+			t.Errorf("sheet %q: workbook.xml has no %s:\n%s", tt.sheet, tt.want, workbook)
+		}
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	tests := map[int]string{
+		0:   "A",
+		1:   "B",
+		25:  "Z",
+		26:  "AA",
+		27:  "AB",
+		51:  "AZ",
+		52:  "BA",
+		701: "ZZ",
+		702: "AAA",
+	}
+	for i, want := range tests {
+		if got := columnName(i); got != want {
+			t.Errorf("columnName(%d) = %q, want %q", i, got, want)
+		}
+	}
+}
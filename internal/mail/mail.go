@@ -0,0 +1,80 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sharon-xa/high-api/internal/config"
+	"gopkg.in/gomail.v2"
+)
+
+// Drivers a mailer can be configured with.
+const (
+	DriverSMTP   = "smtp"
+	DriverFile   = "file"
+	DriverMemory = "memory"
+)
+
+// Message is an email to a single recipient. Messages with a Text body are
+// sent as multipart/alternative, with HTML as the preferred part.
+type Message struct {
+	To      string
+	Subject string
+	HTML    string
+	Text    string
+	// Headers are extra headers, such as List-Unsubscribe.
+	Headers map[string]string
+}
+
+// SetListUnsubscribe adds the one-click unsubscribe headers (RFC 8058) for
+// url.
+func (m *Message) SetListUnsubscribe(url string) {
+	if m.Headers == nil {
+		m.Headers = map[string]string{}
+	}
+	m.Headers["List-Unsubscribe"] = "<" + url + ">"
+	m.Headers["List-Unsubscribe-Post"] = "List-Unsubscribe=One-Click"
+}
+
+// Mailer sends emails.
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// New returns the mailer of the driver set in env.
+func New(env *config.Env) (Mailer, error) {
+	switch env.MailDriver {
+	case DriverSMTP:
+		return NewSMTP(SMTPConfig{
+			Host:     env.SMTPHost,
+			Port:     env.SMTPPort,
+			TLS:      env.SMTPTLS,
+			Username: env.SMTPUsername,
+			Password: env.Password,
+			From:     env.Email,
+		})
+	case DriverFile:
+		return NewFile(env.MailDir, env.Email)
+	case DriverMemory:
+		return NewRecorder(), nil
+	}
+	return nil, fmt.Errorf("mail: unknown driver %q", env.MailDriver)
+}
+
+// build turns m into a message sent from from.
+func build(from string, m Message) *gomail.Message {
+	msg := gomail.NewMessage()
+	msg.SetHeader("From", from)
+	msg.SetHeader("To", m.To)
+	msg.SetHeader("Subject", m.Subject)
+	for name, value := range m.Headers {
+		msg.SetHeader(name, value)
+	}
+	if m.Text != "" {
+		msg.SetBody("text/plain", m.Text)
+		msg.AddAlternative("text/html", m.HTML)
+	} else {
+		msg.SetBody("text/html", m.HTML)
+	}
+	return msg
+}
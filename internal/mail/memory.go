@@ -0,0 +1,45 @@
+package mail
+
+import (
+	"context"
+	"sync"
+)
+
+// Recorder keeps the emails it is given in memory instead of sending them,
+// so tests can check what would have been sent.
+type Recorder struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (r *Recorder) Send(ctx context.Context, m Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.messages = append(r.messages, m)
+	return nil
+}
+
+// Messages returns the emails recorded so far, oldest first.
+func (r *Recorder) Messages() []Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Message(nil), r.messages...)
+}
+
+// Reset forgets the recorded emails.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.messages = nil
+}
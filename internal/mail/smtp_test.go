@@ -0,0 +1,304 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSMTP(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     SMTPConfig
+		wantErr bool
+	}{
+		{name: "implicit tls", cfg: SMTPConfig{Host: "smtp.example", Port: 465, TLS: TLSImplicit}},
+		{name: "starttls", cfg: SMTPConfig{Host: "smtp.example", Port: 587, TLS: TLSStartTLS}},
+		{name: "no tls", cfg: SMTPConfig{Host: "localhost", Port: 1025, TLS: TLSNone}},
+		{
+			name:    "empty tls mode",
+			cfg:     SMTPConfig{Host: "smtp.example", Port: 587},
+			wantErr: true,
+		},
+		{
+			name:    "unknown tls mode",
+			cfg:     SMTPConfig{Host: "smtp.example", Port: 587, TLS: "ssl"},
+			wantErr: true,
+		},
+		{
+			name:    "missing host",
+			cfg:     SMTPConfig{Port: 587, TLS: TLSStartTLS},
+			wantErr: true,
+		},
+		{
+			name:    "missing port",
+			cfg:     SMTPConfig{Host: "smtp.example", TLS: TLSStartTLS},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSMTP(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewSMTP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// testCertificate returns the certificate of httptest's TLS servers, valid
+// for 127.0.0.1 and example.com, and a pool trusting it.
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	srv := httptest.NewTLSServer(nil)
+	defer srv.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	return srv.TLS.Certificates[0], pool
+}
+
+// fakeSMTP is an SMTP server accepting every email, which it passes on to
+// messages.
+type fakeSMTP struct {
+	implicitTLS   bool
+	offerStartTLS bool
+	tlsConfig     *tls.Config
+	messages      chan string
+}
+
+// start serves on a local port until the test ends, and returns the port.
+func (f *fakeSMTP) start(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.implicitTLS {
+		ln = tls.NewListener(ln, f.tlsConfig)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func (f *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+
+	offerStartTLS := f.offerStartTLS
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, _, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			if offerStartTLS {
+				tp.PrintfLine("250-localhost")
+				tp.PrintfLine("250 STARTTLS")
+			} else {
+				tp.PrintfLine("250 localhost")
+			}
+		case "STARTTLS":
+			tp.PrintfLine("220 ready to start TLS")
+			tlsConn := tls.Server(conn, f.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			tp = textproto.NewConn(conn)
+			offerStartTLS = false
+		case "DATA":
+			tp.PrintfLine("354 end with <CR><LF>.<CR><LF>")
+			body, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			f.messages <- string(body)
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("250 ok")
+		}
+	}
+}
+
+func TestSMTPSend(t *testing.T) {
+	cert, pool := testCertificate(t)
+
+	tests := []struct {
+		name          string
+		tls           string
+		host          string
+		implicitTLS   bool
+		offerStartTLS bool
+		trusted       bool
+		wantErr       bool
+	}{
+		{
+			name: "no tls",
+			tls:  TLSNone,
+		},
+		{
+			name:          "starttls",
+			tls:           TLSStartTLS,
+			offerStartTLS: true,
+			trusted:       true,
+		},
+		{
+			name:    "starttls not offered",
+			tls:     TLSStartTLS,
+			trusted: true,
+			wantErr: true,
+		},
+		{
+			name:          "starttls untrusted certificate",
+			tls:           TLSStartTLS,
+			offerStartTLS: true,
+			wantErr:       true,
+		},
+		{
+			name:          "starttls certificate for another host",
+			tls:           TLSStartTLS,
+			host:          "localhost",
+			offerStartTLS: true,
+			trusted:       true,
+			wantErr:       true,
+		},
+		{
+			name:        "implicit tls",
+			tls:         TLSImplicit,
+			implicitTLS: true,
+			trusted:     true,
+		},
+		{
+			name:        "implicit tls untrusted certificate",
+			tls:         TLSImplicit,
+			implicitTLS: true,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &fakeSMTP{
+				implicitTLS:   tt.implicitTLS,
+				offerStartTLS: tt.offerStartTLS,
+				tlsConfig:     &tls.Config{Certificates: []tls.Certificate{cert}},
+				messages:      make(chan string, 1),
+			}
+			port := server.start(t)
+
+			host := tt.host
+			if host == "" {
+				host = "127.0.0.1"
+			}
+			mailer, err := NewSMTP(SMTPConfig{
+				Host: host,
+				Port: port,
+				TLS:  tt.tls,
+				From: "noreply@example.com",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.trusted {
+				mailer.rootCAs = pool
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err = mailer.Send(ctx, Message{
+				To:      "user@example.com",
+				Subject: "Hello",
+				Text:    "Hi there",
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			select {
+			case body := <-server.messages:
+				if tt.wantErr {
+					t.Errorf("Send() failed but the server got an email:\n%s", body)
+				} else if !strings.Contains(body, "Subject: Hello") {
+					t.Errorf("the server got an email without the subject:\n%s", body)
+				}
+			default:
+				if !tt.wantErr {
+					t.Error("Send() succeeded but the server got no email")
+				}
+			}
+		})
+	}
+}
+
+func TestSMTPSendCancel(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// The server greets and then never answers.
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "220 localhost ESMTP\r\n")
+		io.Copy(io.Discard, conn)
+	}()
+
+	mailer, err := NewSMTP(SMTPConfig{
+		Host: "127.0.0.1",
+		Port: ln.Addr().(*net.TCPAddr).Port,
+		TLS:  TLSNone,
+		From: "noreply@example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- mailer.Send(ctx, Message{To: "user@example.com", Subject: "Hello"})
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Send() error = nil after the context was cancelled")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send() didn't return after the context was cancelled")
+	}
+}
@@ -0,0 +1,159 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Emails that can be composed.
+const (
+	TemplateVerification  = "verification"
+	TemplateResetPassword = "reset_password"
+	TemplateNotification  = "notification"
+	TemplateDigest        = "digest"
+)
+
+var templateNames = []string{
+	TemplateVerification,
+	TemplateResetPassword,
+	TemplateNotification,
+	TemplateDigest,
+}
+
+const DefaultLocale = "en"
+
+// Locales lists the locales emails are written in.
+var Locales = []string{DefaultLocale, "ar"}
+
+// Layout holds what the shared layout of every email shows. Template data
+// embeds it.
+type Layout struct {
+	SiteName string
+	// UnsubscribeURL, when set, is linked from the footer.
+	UnsubscribeURL string
+}
+
+type VerificationData struct {
+	Layout
+	OTP          string
+	ExpiresInMin int
+}
+
+type ResetPasswordData struct {
+	Layout
+	ResetURL     string
+	ExpiresInMin int
+}
+
+// NotificationItem is a notification as emails describe it. Message is only
+// shown for types the templates don't know.
+type NotificationItem struct {
+	Type      string
+	Actor     string
+	PostTitle string
+	Message   string
+	Link      string
+}
+
+type NotificationData struct {
+	Layout
+	Name string
+	Item NotificationItem
+}
+
+// DigestData lists some of the notifications of a digest, More counts the
+// others.
+type DigestData struct {
+	Layout
+	Name  string
+	Items []NotificationItem
+	More  int64
+}
+
+// templateFiles holds an html and a text file per email and locale, under
+// "templates/locale/name". Email files define a "content" template, text
+// ones a "subject" template as well, and are parsed along with the layout of
+// their locale.
+//
+//go:embed templates
+var templateFiles embed.FS
+
+// The parsed templates, by "locale/name".
+var (
+	htmlTemplates = map[string]*htmltemplate.Template{}
+	textTemplates = map[string]*texttemplate.Template{}
+)
+
+func init() {
+	for _, locale := range Locales {
+		for _, name := range templateNames {
+			key := locale + "/" + name
+			htmlTemplates[key] = htmltemplate.Must(htmltemplate.ParseFS(
+				templateFiles,
+				"templates/styles.html",
+				"templates/"+locale+"/layout.html",
+				"templates/"+key+".html",
+			))
+			textTemplates[key] = texttemplate.Must(texttemplate.ParseFS(
+				templateFiles,
+				"templates/"+locale+"/layout.txt",
+				"templates/"+key+".txt",
+			))
+		}
+	}
+}
+
+// SupportedLocale reports whether emails are written in locale.
+func SupportedLocale(locale string) bool {
+	return slices.Contains(Locales, locale)
+}
+
+// Locale picks the first supported locale of a comma separated list of
+// language tags, such as an Accept-Language header, falling back to
+// DefaultLocale.
+func Locale(tags string) string {
+	for tag := range strings.SplitSeq(tags, ",") {
+		tag, _, _ = strings.Cut(tag, ";")
+		tag, _, _ = strings.Cut(strings.TrimSpace(tag), "-")
+		if tag = strings.ToLower(tag); SupportedLocale(tag) {
+			return tag
+		}
+	}
+	return DefaultLocale
+}
+
+// Compose renders the email name in locale, falling back to DefaultLocale,
+// into a message to to with both an HTML and a plain text body.
+func Compose(to, locale, name string, data any) (Message, error) {
+	if !SupportedLocale(locale) {
+		locale = DefaultLocale
+	}
+	key := locale + "/" + name
+	htmlTemplate, textTemplate := htmlTemplates[key], textTemplates[key]
+	if htmlTemplate == nil || textTemplate == nil {
+		return Message{}, fmt.Errorf("mail: unknown template %q", name)
+	}
+
+	var subject, html, text bytes.Buffer
+	if err := textTemplate.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := htmlTemplate.ExecuteTemplate(&html, "layout.html", data); err != nil {
+		return Message{}, err
+	}
+	if err := textTemplate.ExecuteTemplate(&text, "layout.txt", data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		To:      to,
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		HTML:    html.String(),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+	}, nil
+}
@@ -1,22 +1,25 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sharon-xa/high-api/internal/auth"
+	"github.com/sharon-xa/high-api/internal/database"
+	"github.com/sharon-xa/high-api/internal/rbac"
 	"github.com/sharon-xa/high-api/internal/utils"
+	"gorm.io/gorm"
 )
 
-func Admin(accessTokenSecret string) gin.HandlerFunc {
+// Require lets the request through when the role of the caller grants
+// permission. It must run after User.
+func Require(permission rbac.Permission) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		claims := auth.GetAccessClaimsFromAuthHeader(c, accessTokenSecret)
-
-		if claims == nil {
-			return
-		}
-
-		if claims.Role != "admin" {
+		claimsValue, _ := c.Get("claims")
+		claims, ok := claimsValue.(*auth.AccessClaims)
+		if !ok || !rbac.Can(claims.Role, permission) {
 			utils.FailAndAbort(
 				c,
 				utils.NewAPIError(http.StatusForbidden, utils.ErrRoleNotAllowed.Error()),
@@ -25,12 +28,16 @@ func Admin(accessTokenSecret string) gin.HandlerFunc {
 			return
 		}
 
-		c.Set("claims", claims)
 		c.Next()
 	}
 }
 
-func User(accessTokenSecret string) gin.HandlerFunc {
+// User lets requests with a valid access token through. The role of the
+// claims is replaced with the one in the database, so that role changes
+// apply right away rather than once the token expires. The user it loaded,
+// with their id, role and banned flag, is set as "user" for the handlers
+// after it.
+func User(accessTokenSecret string, db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		claims := auth.GetAccessClaimsFromAuthHeader(c, accessTokenSecret)
 
@@ -38,7 +45,38 @@ func User(accessTokenSecret string) gin.HandlerFunc {
 			return
 		}
 
+		var user database.User
+		err := db.Select("id", "role", "banned").
+			Where("id = ?", claims.Subject).
+			Take(&user).
+			Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.FailAndAbort(c, utils.ErrInvalidToken, nil)
+			return
+		}
+		if err != nil {
+			utils.FailAndAbort(c, utils.ErrInternal, err)
+			return
+		}
+		claims.Role = user.Role
+
 		c.Set("claims", claims)
+		c.Set("user", &user)
+		c.Next()
+	}
+}
+
+// OptionalUser sets the claims when the request carries a valid access
+// token, and lets anonymous requests (or ones with a bad token) through.
+func OptionalUser(accessTokenSecret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if tokenString != "" {
+			if claims, err := auth.ParseAccessToken(tokenString, accessTokenSecret); err == nil {
+				c.Set("claims", claims)
+			}
+		}
+
 		c.Next()
 	}
 }
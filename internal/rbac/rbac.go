@@ -0,0 +1,72 @@
+package rbac
+
+import "slices"
+
+// Permission is something a role may be allowed to do.
+type Permission string
+
+const (
+	PostsDeleteAny    Permission = "posts.delete.any"
+	PostsHistoryAny   Permission = "posts.history.any"
+	CommentsDeleteAny Permission = "comments.delete.any"
+	CommentsModerate  Permission = "comments.moderate"
+	ReportsManage     Permission = "reports.manage"
+	UsersView         Permission = "users.view"
+	UsersBan          Permission = "users.ban"
+	RolesManage       Permission = "roles.manage"
+	CategoriesManage  Permission = "categories.manage"
+	TagsManage        Permission = "tags.manage"
+	StatsView         Permission = "stats.view"
+	DataExport        Permission = "data.export"
+	AuditView         Permission = "audit.view"
+	EmailsManage      Permission = "emails.manage"
+)
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Roles lists the roles from the least to the most privileged.
+var Roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+var moderatorPermissions = []Permission{
+	PostsDeleteAny,
+	PostsHistoryAny,
+	CommentsDeleteAny,
+	CommentsModerate,
+	ReportsManage,
+	UsersView,
+	UsersBan,
+}
+
+var rolePermissions = map[string][]Permission{
+	RoleUser:      nil,
+	RoleModerator: moderatorPermissions,
+	RoleAdmin: append(
+		slices.Clone(moderatorPermissions),
+		RolesManage,
+		CategoriesManage,
+		TagsManage,
+		StatsView,
+		DataExport,
+		AuditView,
+		EmailsManage,
+	),
+}
+
+// Can reports whether role grants permission. Unknown roles grant nothing.
+func Can(role string, permission Permission) bool {
+	return slices.Contains(rolePermissions[role], permission)
+}
+
+// ValidRole reports whether role exists.
+func ValidRole(role string) bool {
+	return slices.Contains(Roles, role)
+}
+
+// Outranks reports whether role is more privileged than other.
+func Outranks(role, other string) bool {
+	return slices.Index(Roles, role) > slices.Index(Roles, other)
+}
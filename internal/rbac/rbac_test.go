@@ -0,0 +1,55 @@
+package rbac
+
+import "testing"
+
+func TestCan(t *testing.T) {
+	tests := []struct {
+		role       string
+		permission Permission
+		want       bool
+	}{
+		{RoleUser, PostsDeleteAny, false},
+		{RoleUser, UsersView, false},
+		{RoleModerator, CommentsModerate, true},
+		{RoleModerator, UsersBan, true},
+		{RoleModerator, RolesManage, false},
+		{RoleModerator, DataExport, false},
+		{RoleAdmin, UsersBan, true},
+		{RoleAdmin, RolesManage, true},
+		{RoleAdmin, EmailsManage, true},
+		{"", UsersView, false},
+		{"superuser", UsersView, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role+"/"+string(tt.permission), func(t *testing.T) {
+			if got := Can(tt.role, tt.permission); got != tt.want {
+				t.Errorf("Can(%q, %q) = %v, want %v", tt.role, tt.permission, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutranks(t *testing.T) {
+	tests := []struct {
+		role, other string
+		want        bool
+	}{
+		{RoleAdmin, RoleModerator, true},
+		{RoleAdmin, RoleUser, true},
+		{RoleModerator, RoleUser, true},
+		{RoleAdmin, RoleAdmin, false},
+		{RoleModerator, RoleModerator, false},
+		{RoleModerator, RoleAdmin, false},
+		{RoleUser, RoleModerator, false},
+		{"superuser", RoleUser, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role+">"+tt.other, func(t *testing.T) {
+			if got := Outranks(tt.role, tt.other); got != tt.want {
+				t.Errorf("Outranks(%q, %q) = %v, want %v", tt.role, tt.other, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharon-xa/high-api/internal/auth"
+	"github.com/sharon-xa/high-api/internal/database"
+	"github.com/sharon-xa/high-api/internal/utils"
+	"gorm.io/gorm"
+)
+
+// auditEntry describes a privileged action for the audit log. Before and
+// After are snapshots of the target, either may be nil.
+type auditEntry struct {
+	Action     string
+	TargetType string
+	TargetID   uint
+	Before     any
+	After      any
+}
+
+// audit appends entry to the audit log on behalf of the caller. It runs on
+// the transaction of the action, so that actions and their records are only
+// ever kept together.
+func audit(c *gin.Context, tx *gorm.DB, entry auditEntry) error {
+	claimsValue, _ := c.Get("claims")
+	claims, ok := claimsValue.(*auth.AccessClaims)
+	if !ok {
+		return errors.New("audit: the request carries no claims")
+	}
+
+	event := database.AuditEvent{
+		ActorID:    getOptionalUserID(c),
+		ActorRole:  claims.Role,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		IP:         c.ClientIP(),
+	}
+
+	var err error
+	if event.Before, err = auditSnapshot(entry.Before); err != nil {
+		return err
+	}
+	if event.After, err = auditSnapshot(entry.After); err != nil {
+		return err
+	}
+
+	return tx.Create(&event).Error
+}
+
+func auditSnapshot(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+type auditEventResponse struct {
+	ID         uint            `json:"id"`
+	ActorID    uint            `json:"actor_id"`
+	ActorRole  string          `json:"actor_role"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   uint            `json:"target_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	IP         string          `json:"ip"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// auditFilters are the query parameters of the audit log.
+type auditFilters struct {
+	ActorID    uint
+	Action     string
+	TargetType string
+	TargetID   uint
+	From       time.Time
+	To         time.Time
+}
+
+func getAuditFilters(c *gin.Context) (f auditFilters, ok bool) {
+	if f.ActorID, ok = getOptionalQueryUInt(c, "actor"); !ok {
+		return f, false
+	}
+	f.Action = c.Query("action")
+	f.TargetType = c.Query("targetType")
+	if f.TargetID, ok = getOptionalQueryUInt(c, "targetId"); !ok {
+		return f, false
+	}
+	if f.TargetID != 0 && f.TargetType == "" {
+		utils.Fail(
+			c,
+			&utils.APIError{
+				Code:    http.StatusBadRequest,
+				Message: "targetId can only be used along with targetType",
+			},
+			nil,
+		)
+		return f, false
+	}
+	if f.From, ok = getOptionalQueryTime(c, "from", false); !ok {
+		return f, false
+	}
+	if f.To, ok = getOptionalQueryTime(c, "to", true); !ok {
+		return f, false
+	}
+	return f, true
+}
+
+// apply narrows a query on the audit_events table.
+func (f auditFilters) apply(q *gorm.DB) *gorm.DB {
+	if f.ActorID != 0 {
+		q = q.Where("audit_events.actor_id = ?", f.ActorID)
+	}
+	if f.Action != "" {
+		q = q.Where("audit_events.action = ?", f.Action)
+	}
+	if f.TargetType != "" {
+		q = q.Where("audit_events.target_type = ?", f.TargetType)
+	}
+	if f.TargetID != 0 {
+		q = q.Where("audit_events.target_id = ?", f.TargetID)
+	}
+	if !f.From.IsZero() {
+		q = q.Where("audit_events.created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		q = q.Where("audit_events.created_at < ?", f.To)
+	}
+	return q
+}
+
+// getAuditEvents lists the audit log, newest first.
+func (s *Server) getAuditEvents(c *gin.Context) {
+	filters, ok := getAuditFilters(c)
+	if !ok {
+		return
+	}
+	after, ok := getCursor(c)
+	if !ok {
+		return
+	}
+	limit := getPageLimit(c)
+
+	q := filters.apply(s.db.Model(&database.AuditEvent{}))
+
+	var total int64
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	page := q.Session(&gorm.Session{})
+	if after != nil {
+		page = page.Where("(audit_events.created_at, audit_events.id) < (?, ?)", after.Time, after.ID)
+	}
+
+	var events []database.AuditEvent
+	err := page.Order("audit_events.created_at DESC, audit_events.id DESC").
+		Limit(limit + 1).
+		Find(&events).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	nextCursor := ""
+	if len(events) > limit {
+		events = events[:limit]
+		last := events[limit-1]
+		nextCursor = encodeCursor(cursor{Time: last.CreatedAt, ID: last.ID})
+	}
+
+	response := make([]auditEventResponse, len(events))
+	for i, e := range events {
+		response[i] = auditEventResponse{
+			ID:         e.ID,
+			ActorID:    e.ActorID,
+			ActorRole:  e.ActorRole,
+			Action:     e.Action,
+			TargetType: e.TargetType,
+			TargetID:   e.TargetID,
+			Before:     e.Before,
+			After:      e.After,
+			IP:         e.IP,
+			CreatedAt:  e.CreatedAt,
+		}
+	}
+
+	utils.SuccessPageWithTotal(c, "", response, nextCursor, total)
+}
@@ -9,10 +9,17 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/sharon-xa/high-api/internal/auth"
 	"github.com/sharon-xa/high-api/internal/database"
+	"github.com/sharon-xa/high-api/internal/mail"
+	"github.com/sharon-xa/high-api/internal/rbac"
 	"github.com/sharon-xa/high-api/internal/utils"
 	"gorm.io/gorm"
 )
 
+var errUnsupportedLanguage = &utils.APIError{
+	Code:    http.StatusBadRequest,
+	Message: "unsupported language",
+}
+
 type registerReq struct {
 	Name      string `json:"name"      binding:"required"`
 	Email     string `json:"email"     binding:"required"`
@@ -21,6 +28,9 @@ type registerReq struct {
 	Birthdate string `json:"birthdate" binding:"required"`
 	// use the "YYYY-MM-DD" format.
 	// example: 1998-11-23.
+	Language string `json:"language"`
+	// the locale of the user's emails, taken from the Accept-Language
+	// header when left out.
 }
 
 func (s *Server) register(c *gin.Context) {
@@ -37,9 +47,9 @@ func (s *Server) register(c *gin.Context) {
 		return
 	}
 
-	role := "user"
+	role := rbac.RoleUser
 	if s.env.AdminEmail == req.Email {
-		role = "admin"
+		role = rbac.RoleAdmin
 	}
 
 	layout := "2006-01-02"
@@ -68,6 +78,14 @@ func (s *Server) register(c *gin.Context) {
 		return
 	}
 
+	language := req.Language
+	if language == "" {
+		language = mail.Locale(c.GetHeader("Accept-Language"))
+	} else if !mail.SupportedLocale(language) {
+		utils.Fail(c, errUnsupportedLanguage, nil)
+		return
+	}
+
 	u := database.User{
 		Name:      req.Name,
 		Email:     req.Email,
@@ -77,6 +95,7 @@ func (s *Server) register(c *gin.Context) {
 		Verified:  false,
 		Role:      role,
 		Banned:    false,
+		Language:  language,
 	}
 
 	err = s.db.Transaction(func(tx *gorm.DB) error {
@@ -101,7 +120,7 @@ func (s *Server) register(c *gin.Context) {
 			return err
 		}
 
-		err = auth.SendVerificationEmail(u.Email, otp, s.env)
+		err = auth.SendVerificationEmail(c, s.outbox.queue(tx), u.Email, u.Language, otp, s.env)
 		if err != nil {
 			utils.Fail(c, utils.ErrInternal, err)
 			return err
@@ -111,6 +130,7 @@ func (s *Server) register(c *gin.Context) {
 	if err != nil {
 		return
 	}
+	s.outbox.kick()
 
 	utils.Success(c, "account created", nil)
 }
@@ -191,7 +211,7 @@ func (s *Server) resendVerificationEmail(c *gin.Context) {
 	}
 
 	var user database.User
-	err = s.db.Select("id", "Verified").Where("email = ?", req.Email).First(&user).Error
+	err = s.db.Select("id", "Verified", "language").Where("email = ?", req.Email).First(&user).Error
 	if err != nil {
 		utils.Fail(c, utils.ErrInternal, err)
 		return
@@ -206,7 +226,7 @@ func (s *Server) resendVerificationEmail(c *gin.Context) {
 	err = s.db.Transaction(func(tx *gorm.DB) error {
 		a := database.AccountVerificationOTP{}
 
-		err = s.db.Where("user_id = ?", userID).First(&a).Error
+		err = tx.Where("user_id = ?", userID).First(&a).Error
 		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 			utils.Fail(c, utils.ErrInternal, err)
 			return err
@@ -225,14 +245,21 @@ func (s *Server) resendVerificationEmail(c *gin.Context) {
 				return err
 			}
 		} else {
-			err = s.db.Save(&a).Error
+			err = tx.Save(&a).Error
 			if err != nil {
 				utils.Fail(c, utils.ErrInternal, err)
 				return err
 			}
 		}
 
-		err = auth.SendVerificationEmail(req.Email, otp, s.env)
+		err = auth.SendVerificationEmail(
+			c,
+			s.outbox.queue(tx),
+			req.Email,
+			user.Language,
+			otp,
+			s.env,
+		)
 		if err != nil {
 			utils.Fail(c, utils.ErrInternal, err)
 			return err
@@ -242,6 +269,7 @@ func (s *Server) resendVerificationEmail(c *gin.Context) {
 	if err != nil {
 		return
 	}
+	s.outbox.kick()
 
 	c.JSON(http.StatusOK, userID)
 }
@@ -289,11 +317,7 @@ func (s *Server) login(c *gin.Context) {
 		return
 	}
 
-	if u.Banned {
-		utils.Fail(c, &utils.APIError{
-			Code:    http.StatusForbidden,
-			Message: "Your account has been banned.",
-		}, nil)
+	if !s.rejectBanned(c, &u) {
 		return
 	}
 
@@ -514,6 +538,20 @@ func (s *Server) refreshTokens(c *gin.Context) {
 		return
 	}
 
+	// Bans revoke the sessions of the user, tell them why before saying the
+	// session is over.
+	u := database.User{}
+	err = s.db.Select("id", "role", "banned").First(&u, r.UserID).Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+	userID, role := u.ID, u.Role
+
+	if !s.rejectBanned(c, &u) {
+		return
+	}
+
 	if r.Revoked {
 		utils.Fail(
 			c,
@@ -543,22 +581,6 @@ func (s *Server) refreshTokens(c *gin.Context) {
 		return
 	}
 
-	u := database.User{}
-	err = s.db.Select("id", "role", "banned").First(&u).Error
-	if err != nil {
-		utils.Fail(c, utils.ErrInternal, err)
-		return
-	}
-	userID, role, banned := u.ID, u.Role, u.Banned
-
-	if banned {
-		utils.Fail(c, &utils.APIError{
-			Code:    http.StatusForbidden,
-			Message: "Your account has been banned.",
-		}, nil)
-		return
-	}
-
 	newRefreshToken, err := auth.GenerateRefreshToken(
 		strconv.Itoa(int(userID)),
 		s.env.RefreshTokenSecret,
@@ -622,7 +644,7 @@ func (s *Server) forgotPassword(c *gin.Context) {
 	}
 
 	var user database.User
-	err = s.db.Select("id", "Verified").Where("email = ?", req.Email).First(&user).Error
+	err = s.db.Select("id", "Verified", "language").Where("email = ?", req.Email).First(&user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			utils.Success(
@@ -650,7 +672,7 @@ func (s *Server) forgotPassword(c *gin.Context) {
 	err = s.db.Transaction(func(tx *gorm.DB) error {
 		p := database.PasswordResetToken{}
 
-		err = s.db.Where("user_id = ?", userID).First(&p).Error
+		err = tx.Where("user_id = ?", userID).First(&p).Error
 		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 			utils.Fail(c, utils.ErrInternal, err)
 			return err
@@ -673,14 +695,21 @@ func (s *Server) forgotPassword(c *gin.Context) {
 				return err
 			}
 		} else {
-			err = s.db.Save(&p).Error
+			err = tx.Save(&p).Error
 			if err != nil {
 				utils.Fail(c, utils.ErrInternal, err)
 				return err
 			}
 		}
 
-		err = auth.SendResetPasswordEmail(req.Email, token, s.env)
+		err = auth.SendResetPasswordEmail(
+			c,
+			s.outbox.queue(tx),
+			req.Email,
+			user.Language,
+			token,
+			s.env,
+		)
 		if err != nil {
 			utils.Fail(c, utils.ErrInternal, err)
 			return err
@@ -691,6 +720,7 @@ func (s *Server) forgotPassword(c *gin.Context) {
 	if err != nil {
 		return
 	}
+	s.outbox.kick()
 
 	utils.Success(
 		c,
@@ -0,0 +1,346 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharon-xa/high-api/internal/database"
+	"github.com/sharon-xa/high-api/internal/rbac"
+	"github.com/sharon-xa/high-api/internal/utils"
+	"gorm.io/gorm"
+)
+
+const maxBanReasonLen = 500
+
+// errBanNotAllowed keeps staff from banning or unbanning their peers and
+// superiors.
+var errBanNotAllowed = &utils.APIError{
+	Code:    http.StatusForbidden,
+	Message: "you can only ban or unban users with a lower role than yours",
+}
+
+// banAccount bans a user, replacing the ban they may already have, and
+// revokes all their sessions. A nil expiresAt bans them for good.
+func banAccount(
+	tx *gorm.DB,
+	userID uint,
+	issuerID *uint,
+	reason string,
+	expiresAt *time.Time,
+) error {
+	if err := liftBans(tx, userID, issuerID); err != nil {
+		return err
+	}
+
+	err := tx.Create(&database.Ban{
+		UserID:    userID,
+		IssuerID:  issuerID,
+		Reason:    reason,
+		ExpiresAt: expiresAt,
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	err = tx.Model(&database.User{}).Where("id = ?", userID).Update("banned", true).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Model(&database.RefreshToken{}).
+		Where("user_id = ?", userID).
+		Update("revoked", true).
+		Error
+}
+
+// liftBans ends the bans of a user that are still in effect.
+func liftBans(tx *gorm.DB, userID uint, lifterID *uint) error {
+	now := time.Now()
+	err := tx.Model(&database.Ban{}).
+		Where("user_id = ? AND lifted_at IS NULL", userID).
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		Updates(map[string]any{"lifted_at": now, "lifter_id": lifterID}).
+		Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Model(&database.User{}).Where("id = ?", userID).Update("banned", false).Error
+}
+
+// currentBan returns the ban a user is under, or nil when they aren't
+// banned. Users banned before ban records existed have no record, they get
+// a permanent ban without a reason.
+func currentBan(tx *gorm.DB, user *database.User) (*database.Ban, error) {
+	if !user.Banned {
+		return nil, nil
+	}
+
+	var ban database.Ban
+	err := tx.Where("user_id = ? AND lifted_at IS NULL", user.ID).
+		Order("created_at DESC").
+		First(&ban).
+		Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &database.Ban{UserID: user.ID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The ban ran out before the expiry job got to it.
+	if ban.ExpiresAt != nil && !ban.ExpiresAt.After(time.Now()) {
+		err := tx.Model(user).Update("banned", false).Error
+		return nil, err
+	}
+
+	return &ban, nil
+}
+
+type banInfo struct {
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// banSnapshot describes the ban a user is under for the audit log, it is
+// nil when they aren't banned.
+func banSnapshot(tx *gorm.DB, user *database.User) (*banInfo, error) {
+	ban, err := currentBan(tx, user)
+	if err != nil || ban == nil {
+		return nil, err
+	}
+	return &banInfo{Reason: ban.Reason, ExpiresAt: ban.ExpiresAt}, nil
+}
+
+// rejectBanned responds with the reason and end of the ban when user is
+// banned, it returns false once it responded.
+func (s *Server) rejectBanned(c *gin.Context, user *database.User) bool {
+	ban, err := currentBan(s.db, user)
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return false
+	}
+	if ban == nil {
+		return true
+	}
+
+	message := "Your account has been banned."
+	if ban.ExpiresAt != nil {
+		message = fmt.Sprintf(
+			"Your account has been banned until %s.",
+			ban.ExpiresAt.UTC().Format(time.RFC1123),
+		)
+	}
+	utils.Respond(
+		c,
+		http.StatusForbidden,
+		false,
+		message,
+		banInfo{Reason: ban.Reason, ExpiresAt: ban.ExpiresAt},
+		nil,
+	)
+	return false
+}
+
+// rejectBannedUsers keeps banned users out of the routes it guards. Their
+// refresh tokens are revoked when they are banned, but the access tokens
+// they hold would work until they expire otherwise. It must run after
+// middleware.User, whose lookup of the user it reuses.
+func (s *Server) rejectBannedUsers(c *gin.Context) {
+	userValue, _ := c.Get("user")
+	user, ok := userValue.(*database.User)
+	if !ok {
+		utils.FailAndAbort(c, utils.ErrInternal, errors.New("no user loaded before the ban check"))
+		return
+	}
+	if !s.rejectBanned(c, user) {
+		c.Abort()
+		return
+	}
+
+	c.Next()
+}
+
+type banReq struct {
+	Reason string `json:"reason" binding:"required"`
+	// ExpiresAt is optional, the ban is permanent without it.
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+func (s *Server) banUser(c *gin.Context) {
+	claims := getAccessClaims(c)
+	if claims == nil {
+		return
+	}
+
+	userID := convParamToInt(c, "id")
+	if userID == 0 {
+		return
+	}
+
+	var req banReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+	if len(req.Reason) > maxBanReasonLen {
+		utils.Fail(
+			c,
+			&utils.APIError{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("reason can't be longer than %d characters", maxBanReasonLen),
+			},
+			nil,
+		)
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		utils.Fail(
+			c,
+			&utils.APIError{Code: http.StatusBadRequest, Message: "expiresAt must be in the future"},
+			nil,
+		)
+		return
+	}
+
+	issuerID := convStrToUInt(c, claims.Subject, "user id")
+	if issuerID == 0 {
+		return
+	}
+	if issuerID == userID {
+		utils.Fail(
+			c,
+			&utils.APIError{Code: http.StatusBadRequest, Message: "you can't ban yourself"},
+			nil,
+		)
+		return
+	}
+
+	var user database.User
+	if err := s.db.Select("id", "role", "banned").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.Fail(c, utils.ErrNotFound, err)
+			return
+		}
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+	if !rbac.Outranks(claims.Role, user.Role) {
+		utils.Fail(c, errBanNotAllowed, nil)
+		return
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		before, err := banSnapshot(tx, &user)
+		if err != nil {
+			return err
+		}
+		if err := banAccount(tx, user.ID, &issuerID, req.Reason, req.ExpiresAt); err != nil {
+			return err
+		}
+		return audit(c, tx, auditEntry{
+			Action:     database.AuditActionUserBan,
+			TargetType: database.AuditTargetUser,
+			TargetID:   user.ID,
+			Before:     before,
+			After:      banInfo{Reason: req.Reason, ExpiresAt: req.ExpiresAt},
+		})
+	})
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	utils.Success(c, "User banned successfully", nil)
+}
+
+func (s *Server) unbanUser(c *gin.Context) {
+	claims := getAccessClaims(c)
+	if claims == nil {
+		return
+	}
+
+	userID := convParamToInt(c, "id")
+	if userID == 0 {
+		return
+	}
+
+	lifterID := convStrToUInt(c, claims.Subject, "user id")
+	if lifterID == 0 {
+		return
+	}
+
+	var user database.User
+	if err := s.db.Select("id", "role", "banned").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.Fail(c, utils.ErrNotFound, err)
+			return
+		}
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+	if !rbac.Outranks(claims.Role, user.Role) {
+		utils.Fail(c, errBanNotAllowed, nil)
+		return
+	}
+
+	if !user.Banned {
+		utils.Fail(
+			c,
+			&utils.APIError{Code: http.StatusConflict, Message: "user isn't banned"},
+			nil,
+		)
+		return
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		before, err := banSnapshot(tx, &user)
+		if err != nil {
+			return err
+		}
+		if err := liftBans(tx, user.ID, &lifterID); err != nil {
+			return err
+		}
+		return audit(c, tx, auditEntry{
+			Action:     database.AuditActionUserUnban,
+			TargetType: database.AuditTargetUser,
+			TargetID:   user.ID,
+			Before:     before,
+		})
+	})
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	utils.Success(c, "User unbanned successfully", nil)
+}
+
+// expireBans lets users whose ban ran out back in.
+func (s *Server) expireBans(ctx context.Context) error {
+	return s.db.WithContext(ctx).
+		Model(&database.User{}).
+		Where("banned = ?", true).
+		Where(
+			`EXISTS (
+				SELECT 1 FROM bans
+				WHERE bans.user_id = users.id AND bans.lifted_at IS NULL
+				AND bans.expires_at <= ?
+			)`,
+			time.Now(),
+		).
+		Where(
+			`NOT EXISTS (
+				SELECT 1 FROM bans
+				WHERE bans.user_id = users.id AND bans.lifted_at IS NULL
+				AND (bans.expires_at IS NULL OR bans.expires_at > ?)
+			)`,
+			time.Now(),
+		).
+		Update("banned", false).
+		Error
+}
@@ -38,7 +38,17 @@ func (s *Server) addCategory(c *gin.Context) {
 	categoryName := capitalizeString(req.Name)
 	category.Name = categoryName
 
-	err = s.db.Create(&category).Error
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&category).Error; err != nil {
+			return err
+		}
+		return audit(c, tx, auditEntry{
+			Action:     database.AuditActionCategoryCreate,
+			TargetType: database.AuditTargetCategory,
+			TargetID:   category.ID,
+			After:      gin.H{"name": category.Name},
+		})
+	})
 	if err != nil {
 		utils.Fail(c, utils.ErrInternal, err)
 		return
@@ -71,10 +81,22 @@ func (s *Server) updateCategory(c *gin.Context) {
 		return
 	}
 
+	before := gin.H{"name": category.Name}
 	categoryName := capitalizeString(req.Name)
 	category.Name = categoryName
 
-	err = s.db.Save(&category).Error
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&category).Error; err != nil {
+			return err
+		}
+		return audit(c, tx, auditEntry{
+			Action:     database.AuditActionCategoryUpdate,
+			TargetType: database.AuditTargetCategory,
+			TargetID:   category.ID,
+			Before:     before,
+			After:      gin.H{"name": category.Name},
+		})
+	})
 	if err != nil {
 		utils.Fail(c, utils.ErrInternal, err)
 		return
@@ -89,13 +111,27 @@ func (s *Server) deleteCategory(c *gin.Context) {
 		return
 	}
 
-	results := s.db.Delete(&database.Category{}, categoryID)
-	if results.Error != nil {
-		utils.Fail(c, utils.ErrInternal, results.Error)
+	var category database.Category
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&category, categoryID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&category).Error; err != nil {
+			return err
+		}
+		return audit(c, tx, auditEntry{
+			Action:     database.AuditActionCategoryDelete,
+			TargetType: database.AuditTargetCategory,
+			TargetID:   category.ID,
+			Before:     gin.H{"name": category.Name},
+		})
+	})
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.Fail(c, utils.ErrInternal, err)
 		return
 	}
 
-	if results.RowsAffected == 0 {
+	if err != nil {
 		utils.Fail(
 			c,
 			&utils.APIError{Code: http.StatusNotFound, Message: "category not found"},
@@ -2,26 +2,275 @@ package server
 
 import (
 	"errors"
+	"fmt"
+	"maps"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sharon-xa/high-api/internal/database"
+	"github.com/sharon-xa/high-api/internal/rbac"
 	"github.com/sharon-xa/high-api/internal/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+const deletedCommentContent = "[deleted]"
+
+// newUserHoldPeriod is how long comments from new accounts are held for
+// review.
+const newUserHoldPeriod = 24 * time.Hour
+
+// visibleComments is a scope limiting a comments query to what userID may
+// see: approved comments, plus their own ones still awaiting review.
+func visibleComments(userID uint) func(q *gorm.DB) *gorm.DB {
+	return func(q *gorm.DB) *gorm.DB {
+		return q.Where(
+			"comments.status = ? OR (comments.status = ? AND comments.user_id = ?)",
+			database.CommentStatusApproved,
+			database.CommentStatusPending,
+			userID,
+		)
+	}
+}
+
+// newCommentStatus is the status a new comment by userID starts with.
+// Comments from new accounts and from users who were flagged before are
+// held for review.
+func (s *Server) newCommentStatus(userID uint) (string, error) {
+	var user database.User
+	if err := s.db.Select("id", "created_at").First(&user, userID).Error; err != nil {
+		return "", err
+	}
+	if time.Since(user.CreatedAt) < newUserHoldPeriod {
+		return database.CommentStatusPending, nil
+	}
+
+	var flagged bool
+	err := s.db.Model(&database.Comment{}).
+		Unscoped().
+		Select("1").
+		Where("user_id = ? AND flagged = ?", userID, true).
+		Limit(1).
+		Find(&flagged).
+		Error
+	if err != nil {
+		return "", err
+	}
+	if flagged {
+		return database.CommentStatusPending, nil
+	}
+
+	return database.CommentStatusApproved, nil
+}
+
+// newCommentResponse expects the User association of cm to be loaded.
+func newCommentResponse(cm database.Comment) commentResponse {
+	resp := commentResponse{
+		ID:        cm.ID,
+		ParentID:  cm.ParentID,
+		Depth:     cm.Depth,
+		Content:   cm.Content,
+		Status:    cm.Status,
+		CreatedAt: cm.CreatedAt,
+		User: publicUserSummary{
+			ID:    cm.User.ID,
+			Name:  cm.User.Name,
+			Image: cm.User.Image,
+		},
+	}
+	if cm.Tombstoned {
+		resp.Content = deletedCommentContent
+		resp.Deleted = true
+		resp.User = publicUserSummary{}
+	}
+	return resp
+}
+
+// newCommentResponses builds the responses of comments along with their
+// reply counts and reactions.
+func (s *Server) newCommentResponses(
+	c *gin.Context,
+	comments []database.Comment,
+) ([]commentResponse, error) {
+	response := make([]commentResponse, len(comments))
+	ids := make([]uint, len(comments))
+	for i, cm := range comments {
+		response[i] = newCommentResponse(cm)
+		ids[i] = cm.ID
+	}
+
+	var replyCounts []struct {
+		ParentID uint
+		Count    int64
+	}
+	err := s.db.Model(&database.Comment{}).
+		Scopes(visibleComments(getOptionalUserID(c))).
+		Select("parent_id, COUNT(*) AS count").
+		Where("parent_id IN ?", ids).
+		Group("parent_id").
+		Scan(&replyCounts).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	countByParent := make(map[uint]int64, len(replyCounts))
+	for _, rc := range replyCounts {
+		countByParent[rc.ParentID] = rc.Count
+	}
+	for i := range response {
+		response[i].ReplyCount = countByParent[response[i].ID]
+	}
+
+	if err := s.attachCommentReactions(c, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// getCommentReplies lists the direct replies of a comment, a page at a time.
+func (s *Server) getCommentReplies(c *gin.Context) {
+	commentID := convParamToInt(c, "id")
+	if commentID == 0 {
+		return
+	}
+
+	var exists bool
+	err := s.db.Model(&database.Comment{}).
+		Select("1").
+		Joins("JOIN posts ON posts.id = comments.post_id").
+		Scopes(visibleComments(getOptionalUserID(c))).
+		Where("comments.id = ?", commentID).
+		Where(
+			"posts.status = ? AND posts.deleted_at IS NULL",
+			database.PostStatusPublished,
+		).
+		Limit(1).
+		Find(&exists).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+	if !exists {
+		utils.Fail(
+			c,
+			&utils.APIError{Code: http.StatusNotFound, Message: "comment not found"},
+			nil,
+		)
+		return
+	}
+
+	q := s.db.Model(&database.Comment{}).
+		Scopes(visibleComments(getOptionalUserID(c))).
+		Where("comments.parent_id = ?", commentID)
+	s.listComments(c, q)
+}
+
+const (
+	commentSortOldest = "oldest"
+	commentSortNewest = "newest"
+	commentSortTop    = "top"
+)
+
+// commentScoreSQL is the number of reactions a comment got, which is what
+// the "top" sort ranks comments by.
+const commentScoreSQL = "(SELECT COALESCE(SUM(rc.count), 0) FROM reaction_counts AS rc " +
+	"WHERE rc.target_type = 'comment' AND rc.target_id = comments.id)"
+
+// listComments responds with one keyset page of the comments matched by q,
+// in the order given by the "sort" query parameter, along with the total
+// number of matching comments.
+func (s *Server) listComments(c *gin.Context, q *gorm.DB) {
+	sort := c.DefaultQuery("sort", commentSortOldest)
+	if sort != commentSortOldest && sort != commentSortNewest && sort != commentSortTop {
+		utils.Fail(c, &utils.APIError{Code: http.StatusBadRequest, Message: "invalid sort"}, nil)
+		return
+	}
+	after, ok := getCursor(c)
+	if !ok {
+		return
+	}
+	limit := getPageLimit(c)
+
+	var total int64
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	page := q.Session(&gorm.Session{}).Preload("User")
+	switch sort {
+	case commentSortOldest:
+		if after != nil {
+			page = page.Where("(comments.created_at, comments.id) > (?, ?)", after.Time, after.ID)
+		}
+		page = page.Order("comments.created_at ASC, comments.id ASC")
+	case commentSortNewest:
+		if after != nil {
+			page = page.Where("(comments.created_at, comments.id) < (?, ?)", after.Time, after.ID)
+		}
+		page = page.Order("comments.created_at DESC, comments.id DESC")
+	case commentSortTop:
+		if after != nil {
+			page = page.Where("("+commentScoreSQL+", comments.id) < (?, ?)", after.Score, after.ID)
+		}
+		page = page.Order(commentScoreSQL + " DESC, comments.id DESC")
+	}
+
+	var comments []database.Comment
+	if err := page.Limit(limit + 1).Find(&comments).Error; err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	hasMore := len(comments) > limit
+	if hasMore {
+		comments = comments[:limit]
+	}
+
+	response, err := s.newCommentResponses(c, comments)
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	nextCursor := ""
+	if hasMore {
+		last := response[len(response)-1]
+		cur := cursor{Time: last.CreatedAt, ID: last.ID}
+		if sort == commentSortTop {
+			for _, count := range last.Reactions {
+				cur.Score += count
+			}
+		}
+		nextCursor = encodeCursor(cur)
+	}
+
+	utils.SuccessPageWithTotal(c, "", response, nextCursor, total)
+}
+
 type commentReq struct {
 	Content string `json:"content" binding:"required"`
 }
 
+type addCommentReq struct {
+	Content string `json:"content" binding:"required"`
+	// ParentID is set when the comment replies to another comment of the
+	// same post.
+	ParentID *uint `json:"parentId"`
+}
+
 func (s *Server) addComment(c *gin.Context) {
 	claims := getAccessClaims(c)
 	if claims == nil {
 		return
 	}
 
-	var req commentReq
+	var req addCommentReq
 	err := c.ShouldBindJSON(&req)
 	if err != nil {
 		utils.Fail(c, utils.ErrBadRequest, err)
@@ -39,35 +288,113 @@ func (s *Server) addComment(c *gin.Context) {
 		return
 	}
 
+	var exists bool
+	err = s.db.Model(&database.Post{}).
+		Scopes(publishedPosts).
+		Select("1").
+		Where("id = ?", postID).
+		Limit(1).
+		Find(&exists).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+	if !exists {
+		utils.Fail(
+			c,
+			&utils.APIError{Code: http.StatusNotFound, Message: "post not found"},
+			nil,
+		)
+		return
+	}
+
 	var comment database.Comment
 
 	comment.Content = req.Content
 	comment.PostID = postID
 	comment.UserID = uint(userID)
 
+	if req.ParentID != nil {
+		var parent database.Comment
+		err := s.db.Scopes(visibleComments(uint(userID))).
+			Where("post_id = ?", postID).
+			First(&parent, *req.ParentID).
+			Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				utils.Fail(
+					c,
+					&utils.APIError{Code: http.StatusNotFound, Message: "parent comment not found"},
+					err,
+				)
+				return
+			}
+			utils.Fail(c, utils.ErrInternal, err)
+			return
+		}
+
+		if parent.Tombstoned {
+			utils.Fail(
+				c,
+				&utils.APIError{
+					Code:    http.StatusBadRequest,
+					Message: "you can't reply to a deleted comment",
+				},
+				nil,
+			)
+			return
+		}
+		if parent.Depth+1 >= database.MaxCommentDepth {
+			utils.Fail(
+				c,
+				&utils.APIError{
+					Code: http.StatusBadRequest,
+					Message: fmt.Sprintf(
+						"threads can't be more than %d levels deep",
+						database.MaxCommentDepth,
+					),
+				},
+				nil,
+			)
+			return
+		}
+
+		comment.ParentID = &parent.ID
+		comment.Depth = parent.Depth + 1
+	}
+
+	comment.Status, err = s.newCommentStatus(comment.UserID)
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
 	err = s.db.Create(&comment).Error
 	if err != nil {
 		utils.Fail(c, utils.ErrInternal, err)
 		return
 	}
 
-	var user database.User
-	if err := s.db.Select("id", "name", "image").First(&user, comment.UserID).Error; err != nil {
+	err = s.db.Select("id", "name", "image").First(&comment.User, comment.UserID).Error
+	if err != nil {
 		utils.Fail(c, utils.ErrInternal, err)
 		return
 	}
 
-	resp := commentResponse{
-		ID:        comment.ID,
-		Content:   comment.Content,
-		CreatedAt: comment.CreatedAt,
-		User: publicUserSummary{
-			ID:    user.ID,
-			Name:  user.Name,
-			Image: user.Image,
-		},
+	resp := newCommentResponse(comment)
+	resp.reactionSummary = reactionSummary{Reactions: map[string]int64{}}
+
+	if comment.Status == database.CommentStatusPending {
+		utils.Success(c, "comment submitted for review", resp)
+		return
 	}
 
+	s.notifier.notify(notificationEvent{
+		Type:     database.NotificationComment,
+		ActorID:  comment.UserID,
+		TargetID: comment.ID,
+	})
 	utils.Success(c, "comment created successfully", resp)
 }
 
@@ -90,7 +417,7 @@ func (s *Server) updateComment(c *gin.Context) {
 	}
 
 	var comment database.Comment
-	err = s.db.First(&comment, commentID).Error
+	err = s.db.Where("tombstoned = ?", false).First(&comment, commentID).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			utils.Fail(
@@ -128,24 +455,19 @@ func (s *Server) updateComment(c *gin.Context) {
 		return
 	}
 
-	var user database.User
-	if err := s.db.Select("id", "name", "image").First(&user, comment.UserID).Error; err != nil {
+	err = s.db.Select("id", "name", "image").First(&comment.User, comment.UserID).Error
+	if err != nil {
 		utils.Fail(c, utils.ErrInternal, err)
 		return
 	}
 
-	resp := commentResponse{
-		ID:        comment.ID,
-		Content:   comment.Content,
-		CreatedAt: comment.CreatedAt,
-		User: publicUserSummary{
-			ID:    user.ID,
-			Name:  user.Name,
-			Image: user.Image,
-		},
+	response, err := s.newCommentResponses(c, []database.Comment{comment})
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
 	}
 
-	utils.Success(c, "comment updated successfully", resp)
+	utils.Success(c, "comment updated successfully", response[0])
 }
 
 func (s *Server) deleteComment(c *gin.Context) {
@@ -160,7 +482,7 @@ func (s *Server) deleteComment(c *gin.Context) {
 	}
 
 	var comment database.Comment
-	if err := s.db.First(&comment, commentID).Error; err != nil {
+	if err := s.db.Where("tombstoned = ?", false).First(&comment, commentID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			utils.Fail(
 				c,
@@ -179,7 +501,7 @@ func (s *Server) deleteComment(c *gin.Context) {
 		return
 	}
 
-	if comment.UserID != uint(userID) && claims.Role != "admin" {
+	if comment.UserID != uint(userID) && !rbac.Can(claims.Role, rbac.CommentsDeleteAny) {
 		utils.Fail(
 			c,
 			&utils.APIError{
@@ -191,10 +513,435 @@ func (s *Server) deleteComment(c *gin.Context) {
 		return
 	}
 
-	if err := s.db.Delete(&comment).Error; err != nil {
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		before := commentSnapshot(&comment)
+		if err := removeComment(tx, &comment); err != nil {
+			return err
+		}
+		// Only removals of other people's comments go to the audit log.
+		if comment.UserID == uint(userID) {
+			return nil
+		}
+		return audit(c, tx, auditEntry{
+			Action:     database.AuditActionCommentDelete,
+			TargetType: database.AuditTargetComment,
+			TargetID:   comment.ID,
+			Before:     before,
+		})
+	}); err != nil {
 		utils.Fail(c, utils.ErrInternal, err)
 		return
 	}
 
+	if comment.UserID != uint(userID) {
+		s.notifyModeration(
+			comment.UserID,
+			database.NotificationTargetComment,
+			comment.ID,
+			&comment.PostID,
+			"Your comment was removed by a moderator.",
+		)
+	}
 	utils.Success(c, "comment deleted successfully", nil)
 }
+
+// commentSnapshot describes a comment for the audit log.
+func commentSnapshot(comment *database.Comment) gin.H {
+	return gin.H{
+		"user_id": comment.UserID,
+		"post_id": comment.PostID,
+		"content": comment.Content,
+		"status":  comment.Status,
+		"flagged": comment.Flagged,
+		"deleted": comment.DeletedAt.Valid || comment.Tombstoned,
+	}
+}
+
+// removeComment deletes a comment, or tombstones it when it has replies so
+// the thread stays in one piece. Tombstoned ancestors left without replies
+// are deleted along with it. Reactions are kept for when the comment is
+// restored, they are hidden meanwhile.
+func removeComment(tx *gorm.DB, comment *database.Comment) error {
+	for {
+		var replies int64
+		err := tx.Model(&database.Comment{}).Where("parent_id = ?", comment.ID).Count(&replies).Error
+		if err != nil {
+			return err
+		}
+
+		if replies > 0 {
+			return tx.Model(comment).Update("tombstoned", true).Error
+		}
+
+		if err := tx.Delete(comment).Error; err != nil {
+			return err
+		}
+
+		if comment.ParentID == nil {
+			return nil
+		}
+
+		var parent database.Comment
+		err = tx.Where("tombstoned = ?", true).First(&parent, *comment.ParentID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		comment = &parent
+	}
+}
+
+// restoreComment undoes removeComment. Tombstoned ancestors that were
+// deleted along with the comment come back as tombstones.
+func restoreComment(tx *gorm.DB, comment *database.Comment) error {
+	err := tx.Unscoped().Model(comment).Updates(map[string]any{
+		"deleted_at": nil,
+		"tombstoned": false,
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	for parentID := comment.ParentID; parentID != nil; {
+		var parent database.Comment
+		if err := tx.Unscoped().First(&parent, *parentID).Error; err != nil {
+			return err
+		}
+		if !parent.DeletedAt.Valid {
+			return nil
+		}
+
+		if err := tx.Unscoped().Model(&parent).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		parentID = parent.ParentID
+	}
+
+	return nil
+}
+
+type adminCommentResponse struct {
+	ID         uint              `json:"id"`
+	PostID     uint              `json:"post_id"`
+	ParentID   *uint             `json:"parent_id"`
+	Content    string            `json:"content"`
+	Status     string            `json:"status"`
+	Flagged    bool              `json:"flagged"`
+	Tombstoned bool              `json:"tombstoned"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	DeletedAt  *time.Time        `json:"deleted_at"`
+	User       publicUserSummary `json:"user"`
+}
+
+func newAdminCommentResponse(cm database.Comment) adminCommentResponse {
+	resp := adminCommentResponse{
+		ID:         cm.ID,
+		PostID:     cm.PostID,
+		ParentID:   cm.ParentID,
+		Content:    cm.Content,
+		Status:     cm.Status,
+		Flagged:    cm.Flagged,
+		Tombstoned: cm.Tombstoned,
+		CreatedAt:  cm.CreatedAt,
+		UpdatedAt:  cm.UpdatedAt,
+		User: publicUserSummary{
+			ID:    cm.User.ID,
+			Name:  cm.User.Name,
+			Image: cm.User.Image,
+		},
+	}
+	if cm.DeletedAt.Valid {
+		resp.DeletedAt = &cm.DeletedAt.Time
+	}
+	return resp
+}
+
+// adminCommentFilters are the query parameters of the moderation console.
+type adminCommentFilters struct {
+	UserID   uint
+	PostID   uint
+	From     time.Time
+	To       time.Time
+	Status   string
+	Flagged  *bool
+	Contains string
+	// Deleted is "exclude" (the default), "include" or "only".
+	Deleted string
+}
+
+func getAdminCommentFilters(c *gin.Context) (f adminCommentFilters, ok bool) {
+	if f.UserID, ok = getOptionalQueryUInt(c, "user"); !ok {
+		return f, false
+	}
+	if f.PostID, ok = getOptionalQueryUInt(c, "post"); !ok {
+		return f, false
+	}
+	if f.From, ok = getOptionalQueryTime(c, "from", false); !ok {
+		return f, false
+	}
+	if f.To, ok = getOptionalQueryTime(c, "to", true); !ok {
+		return f, false
+	}
+
+	switch f.Status = c.Query("status"); f.Status {
+	case "",
+		database.CommentStatusApproved,
+		database.CommentStatusPending,
+		database.CommentStatusRejected:
+	default:
+		utils.Fail(c, &utils.APIError{Code: http.StatusBadRequest, Message: "invalid status"}, nil)
+		return f, false
+	}
+
+	if f.Flagged, ok = getOptionalQueryBool(c, "flagged"); !ok {
+		return f, false
+	}
+
+	switch f.Deleted = c.DefaultQuery("deleted", "exclude"); f.Deleted {
+	case "exclude", "include", "only":
+	default:
+		utils.Fail(c, &utils.APIError{Code: http.StatusBadRequest, Message: "invalid deleted"}, nil)
+		return f, false
+	}
+
+	f.Contains = strings.TrimSpace(c.Query("q"))
+	return f, true
+}
+
+// apply narrows a query on the comments table.
+func (f adminCommentFilters) apply(q *gorm.DB) *gorm.DB {
+	switch f.Deleted {
+	case "include":
+		q = q.Unscoped()
+	case "only":
+		q = q.Unscoped().Where("comments.deleted_at IS NOT NULL")
+	}
+
+	if f.UserID != 0 {
+		q = q.Where("comments.user_id = ?", f.UserID)
+	}
+	if f.PostID != 0 {
+		q = q.Where("comments.post_id = ?", f.PostID)
+	}
+	if !f.From.IsZero() {
+		q = q.Where("comments.created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		q = q.Where("comments.created_at < ?", f.To)
+	}
+	if f.Status != "" {
+		q = q.Where("comments.status = ?", f.Status)
+	}
+	if f.Flagged != nil {
+		q = q.Where("comments.flagged = ?", *f.Flagged)
+	}
+	if f.Contains != "" {
+		q = q.Where("comments.content ILIKE ?", "%"+escapeLike(f.Contains)+"%")
+	}
+	return q
+}
+
+// getAdminComments lists comments across all posts for moderation, newest
+// first.
+func (s *Server) getAdminComments(c *gin.Context) {
+	filters, ok := getAdminCommentFilters(c)
+	if !ok {
+		return
+	}
+	after, ok := getCursor(c)
+	if !ok {
+		return
+	}
+	limit := getPageLimit(c)
+
+	q := filters.apply(s.db.Model(&database.Comment{}))
+
+	var total int64
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	page := q.Session(&gorm.Session{}).Preload("User")
+	if after != nil {
+		page = page.Where("(comments.created_at, comments.id) < (?, ?)", after.Time, after.ID)
+	}
+
+	var comments []database.Comment
+	err := page.Order("comments.created_at DESC, comments.id DESC").
+		Limit(limit + 1).
+		Find(&comments).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	nextCursor := ""
+	if len(comments) > limit {
+		comments = comments[:limit]
+		last := comments[limit-1]
+		nextCursor = encodeCursor(cursor{Time: last.CreatedAt, ID: last.ID})
+	}
+
+	response := make([]adminCommentResponse, len(comments))
+	for i, cm := range comments {
+		response[i] = newAdminCommentResponse(cm)
+	}
+
+	utils.SuccessPageWithTotal(c, "", response, nextCursor, total)
+}
+
+const maxBulkCommentIDs = 100
+
+type bulkCommentsReq struct {
+	// Action is one of approve, reject, flag, unflag, delete and restore.
+	Action string `json:"action" binding:"required"`
+	IDs    []uint `json:"ids"    binding:"required,min=1"`
+}
+
+type bulkCommentsResponse struct {
+	Affected int64 `json:"affected"`
+}
+
+// moderateComments applies one moderation action to many comments at once.
+func (s *Server) moderateComments(c *gin.Context) {
+	var req bulkCommentsReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+	if len(req.IDs) > maxBulkCommentIDs {
+		utils.Fail(
+			c,
+			&utils.APIError{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("at most %d comments can be moderated at once", maxBulkCommentIDs),
+			},
+			nil,
+		)
+		return
+	}
+
+	var updates map[string]any
+	switch req.Action {
+	case "approve":
+		updates = map[string]any{"status": database.CommentStatusApproved}
+	case "reject":
+		updates = map[string]any{"status": database.CommentStatusRejected}
+	case "flag":
+		updates = map[string]any{"flagged": true}
+	case "unflag":
+		updates = map[string]any{"flagged": false}
+	case "delete", "restore":
+	default:
+		utils.Fail(c, &utils.APIError{Code: http.StatusBadRequest, Message: "invalid action"}, nil)
+		return
+	}
+
+	var (
+		affected int64
+		comments []database.Comment
+		// before holds the comments as they were, to tell who to notify.
+		before []gin.H
+	)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		q := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", req.IDs)
+		switch req.Action {
+		case "restore":
+			q = q.Unscoped().Where("deleted_at IS NOT NULL OR tombstoned")
+		case "delete":
+			q = q.Where("tombstoned = ?", false)
+		}
+		// Deepest first, so removing replies can prune their tombstoned
+		// parents within the same batch.
+		if err := q.Order("depth DESC").Find(&comments).Error; err != nil {
+			return err
+		}
+
+		if updates != nil && len(comments) > 0 {
+			err := tx.Model(&database.Comment{}).Where("id IN ?", req.IDs).Updates(updates).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		before = make([]gin.H, len(comments))
+		for i := range comments {
+			before[i] = commentSnapshot(&comments[i])
+			after := maps.Clone(before[i])
+
+			switch req.Action {
+			case "restore":
+				if err := restoreComment(tx, &comments[i]); err != nil {
+					return err
+				}
+				after["deleted"] = false
+			case "delete":
+				if err := removeComment(tx, &comments[i]); err != nil {
+					return err
+				}
+				after["deleted"] = true
+			default:
+				maps.Copy(after, updates)
+			}
+
+			err := audit(c, tx, auditEntry{
+				Action:     database.AuditActionCommentModerate,
+				TargetType: database.AuditTargetComment,
+				TargetID:   comments[i].ID,
+				Before:     before[i],
+				After:      after,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		affected = int64(len(comments))
+		return nil
+	})
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	moderatorID := getOptionalUserID(c)
+	for i, comment := range comments {
+		if comment.UserID == moderatorID {
+			continue
+		}
+
+		message := ""
+		switch req.Action {
+		case "approve":
+			// Held comments reach the people they concern once approved.
+			if before[i]["status"] != database.CommentStatusApproved {
+				s.notifier.notify(notificationEvent{
+					Type:     database.NotificationComment,
+					ActorID:  comment.UserID,
+					TargetID: comment.ID,
+				})
+			}
+		case "reject":
+			message = "Your comment was rejected by a moderator."
+		case "delete":
+			message = "Your comment was removed by a moderator."
+		case "restore":
+			message = "Your comment was restored by a moderator."
+		}
+		if message != "" {
+			s.notifyModeration(
+				comment.UserID,
+				database.NotificationTargetComment,
+				comment.ID,
+				&comment.PostID,
+				message,
+			)
+		}
+	}
+
+	utils.Success(c, "", bulkCommentsResponse{Affected: affected})
+}
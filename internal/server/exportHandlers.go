@@ -0,0 +1,188 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharon-xa/high-api/internal/database"
+	"github.com/sharon-xa/high-api/internal/export"
+	"github.com/sharon-xa/high-api/internal/utils"
+	"gorm.io/gorm"
+)
+
+// exportQuery builds the query of an export from the request filters, it
+// returns ok=false after responding when they are invalid. The selected
+// columns become the columns of the export, so they are always listed
+// explicitly to keep secrets such as password hashes out.
+//
+// Each export takes the filters of the matching list: users those of
+// /admin/users, posts those of /posts (plus "status"), comments those of
+// /admin/comments. Sorting and paging don't apply, rows come by id.
+type exportQuery func(c *gin.Context) (q *gorm.DB, ok bool)
+
+func (s *Server) exportQueries() map[string]exportQuery {
+	return map[string]exportQuery{
+		"users": s.exportUsersQuery,
+		"posts": func(c *gin.Context) (*gorm.DB, bool) {
+			filters, ok := getPostFilters(c)
+			if !ok {
+				return nil, false
+			}
+			q := filters.apply(s.db.Model(&database.Post{}))
+			if status := c.Query("status"); status != "" {
+				q = q.Where("posts.status = ?", status)
+			}
+			return q.
+				Select(
+					"posts.id, posts.title, posts.slug, posts.status, posts.publish_at, " +
+						"posts.user_id AS author_id, users.name AS author, " +
+						"categories.name AS category, " +
+						"(SELECT string_agg(tags.name, ',' ORDER BY tags.name) FROM post_tags " +
+						"JOIN tags ON tags.id = post_tags.tag_id " +
+						"WHERE post_tags.post_id = posts.id) AS tags, " +
+						"posts.created_at, posts.updated_at",
+				).
+				Joins("LEFT JOIN users ON users.id = posts.user_id").
+				Joins("LEFT JOIN categories ON categories.id = posts.category_id").
+				Order("posts.id"), true
+		},
+		"comments": func(c *gin.Context) (*gorm.DB, bool) {
+			filters, ok := getAdminCommentFilters(c)
+			if !ok {
+				return nil, false
+			}
+			return filters.apply(s.db.Model(&database.Comment{})).
+				Select(
+					"comments.id, comments.post_id, comments.parent_id, " +
+						"comments.user_id AS author_id, users.name AS author, comments.content, " +
+						"comments.status, comments.flagged, comments.tombstoned, " +
+						"comments.created_at, comments.deleted_at",
+				).
+				Joins("LEFT JOIN users ON users.id = comments.user_id").
+				Order("comments.id"), true
+		},
+	}
+}
+
+// exportUsersQuery only takes the filters of the admin user list, the
+// post and comment counts of the list are left out of the export.
+func (s *Server) exportUsersQuery(c *gin.Context) (*gorm.DB, bool) {
+	filters, ok := getAdminUserFilters(c)
+	if !ok {
+		return nil, false
+	}
+	return filters.apply(s.db.Model(&database.User{})).
+		Select(
+			"users.id, users.name, users.email, users.gender, users.role, " +
+				"users.verified, users.banned, users.birthdate, users.created_at",
+		).
+		Order("users.id"), true
+}
+
+// exportData streams /admin/export/:resource as CSV or XLSX, depending on
+// the "format" query parameter. Rows go from the database cursor straight
+// to the response.
+func (s *Server) exportData(c *gin.Context) {
+	resource := c.Param("resource")
+	query, ok := s.exportQueries()[resource]
+	if !ok {
+		utils.Fail(c, utils.ErrNotFound, nil)
+		return
+	}
+
+	format := c.DefaultQuery("format", export.FormatCSV)
+	if format != export.FormatCSV && format != export.FormatXLSX {
+		utils.Fail(c, &utils.APIError{Code: http.StatusBadRequest, Message: "invalid format"}, nil)
+		return
+	}
+
+	q, ok := query(c)
+	if !ok {
+		return
+	}
+
+	// Exports hand out personal data in bulk, so they are audited before
+	// anything gets sent.
+	err := audit(c, s.db, auditEntry{
+		Action:     database.AuditActionDataExport,
+		TargetType: database.AuditTargetExport,
+		After: gin.H{
+			"resource": resource,
+			"format":   format,
+			"query":    c.Request.URL.RawQuery,
+		},
+	})
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	rows, err := q.Rows()
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	// Exports of big tables take longer than the server's write timeout.
+	err = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", resource, time.Now().Format(time.DateOnly), format)
+	c.Header("Content-Type", export.ContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	// Once the body started there is no way to report an error to the
+	// client, the export is cut short and the error logged.
+	if err := writeExport(export.New(c.Writer, format, resource), columns, rows); err != nil {
+		log.Printf(
+			"Export Error: %v | Path: %s | Method: %s\n",
+			err, c.Request.URL.Path, c.Request.Method,
+		)
+	}
+}
+
+func writeExport(w export.Writer, columns []string, rows *sql.Rows) error {
+	if err := w.Write(columns); err != nil {
+		return err
+	}
+
+	values := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	row := make([]string, len(columns))
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		for i, v := range values {
+			row[i] = v.String
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
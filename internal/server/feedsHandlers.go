@@ -0,0 +1,202 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharon-xa/high-api/internal/database"
+	"github.com/sharon-xa/high-api/internal/feed"
+	"github.com/sharon-xa/high-api/internal/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	feedSize   = 20
+	feedMaxAge = 5 * time.Minute
+
+	feedFormatRSS  = "rss"
+	feedFormatAtom = "atom"
+)
+
+// feedScope narrows a feed to a subset of the published posts, it returns
+// ok=false after responding when the scope doesn't exist. link is the
+// frontend page of the scope, it doubles as the id of the feed so that
+// every scope has its own.
+type feedScope func(c *gin.Context) (filters postFilters, title, link string, ok bool)
+
+func (s *Server) postURL(p database.Post) string {
+	return fmt.Sprintf("%s/posts/%s", s.env.FrontendURL, p.Slug)
+}
+
+// feedHandler serves the feed of the posts matched by scope in the given
+// format. Feed readers poll often, so the feed is only built when the
+// matching posts changed since the validators the client sent.
+func (s *Server) feedHandler(format string, scope feedScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filters, title, link, ok := scope(c)
+		if !ok {
+			return
+		}
+
+		var state struct {
+			LastModified *time.Time
+			Count        int64
+		}
+		err := filters.apply(s.db.Model(&database.Post{}).Scopes(publishedPosts)).
+			Select("MAX(posts.updated_at) AS last_modified, COUNT(*) AS count").
+			Scan(&state).
+			Error
+		if err != nil {
+			utils.Fail(c, utils.ErrInternal, err)
+			return
+		}
+
+		lastModified := time.Unix(0, 0).UTC()
+		if state.LastModified != nil {
+			lastModified = state.LastModified.UTC().Truncate(time.Second)
+		}
+		etag := fmt.Sprintf(`W/"%s-%d-%d"`, format, lastModified.Unix(), state.Count)
+
+		c.Header("ETag", etag)
+		c.Header("Last-Modified", lastModified.Format(http.TimeFormat))
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(feedMaxAge.Seconds())))
+
+		if notModified(c, etag, lastModified) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+
+		posts, _, err := findPostsPage(s.postListQuery(filters), nil, feedSize)
+		if err != nil {
+			utils.Fail(c, utils.ErrInternal, err)
+			return
+		}
+
+		f := feed.Feed{
+			Title:       title,
+			Link:        link,
+			Description: "Latest posts on " + s.env.SiteName,
+			Updated:     lastModified,
+		}
+		if s.env.APIURL != "" {
+			f.SelfLink = s.env.APIURL + c.Request.URL.Path
+		}
+
+		for _, p := range posts {
+			post := newPostResponse(p)
+			categories := append([]string{post.Category.Name}, post.Tags...)
+
+			f.Items = append(f.Items, feed.Item{
+				ID:         fmt.Sprintf("%s/posts/%d", s.env.FrontendURL, p.ID),
+				Title:      p.Title,
+				Link:       s.postURL(p),
+				Author:     p.User.Name,
+				Categories: categories,
+				Content:    post.ContentHTML,
+				Published:  publishTime(p),
+				Updated:    p.UpdatedAt,
+			})
+		}
+
+		var body []byte
+		contentType := "application/rss+xml; charset=utf-8"
+		if format == feedFormatAtom {
+			body, err = f.Atom()
+			contentType = "application/atom+xml; charset=utf-8"
+		} else {
+			body, err = f.RSS()
+		}
+		if err != nil {
+			utils.Fail(c, utils.ErrInternal, err)
+			return
+		}
+
+		c.Data(http.StatusOK, contentType, body)
+	}
+}
+
+// notModified evaluates the conditional request headers, If-None-Match
+// taking precedence over If-Modified-Since.
+func notModified(c *gin.Context, etag string, lastModified time.Time) bool {
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		for candidate := range strings.SplitSeq(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := c.GetHeader("If-Modified-Since"); ims != "" {
+		t, err := http.ParseTime(ims)
+		return err == nil && !lastModified.After(t)
+	}
+
+	return false
+}
+
+func (s *Server) siteFeedScope(c *gin.Context) (postFilters, string, string, bool) {
+	return postFilters{}, s.env.SiteName, s.env.FrontendURL, true
+}
+
+func (s *Server) categoryFeedScope(c *gin.Context) (postFilters, string, string, bool) {
+	categoryID := convParamToInt(c, "id")
+	if categoryID == 0 {
+		return postFilters{}, "", "", false
+	}
+
+	var category database.Category
+	if err := s.db.First(&category, categoryID).Error; err != nil {
+		failFeedScope(c, err)
+		return postFilters{}, "", "", false
+	}
+
+	title := fmt.Sprintf("%s - %s", s.env.SiteName, category.Name)
+	link := fmt.Sprintf("%s/categories/%d", s.env.FrontendURL, category.ID)
+	return postFilters{CategoryID: category.ID}, title, link, true
+}
+
+func (s *Server) tagFeedScope(c *gin.Context) (postFilters, string, string, bool) {
+	tagName := strings.ToLower(strings.TrimSpace(c.Param("name")))
+
+	var tag database.Tag
+	if err := s.db.Where("name = ?", tagName).First(&tag).Error; err != nil {
+		failFeedScope(c, err)
+		return postFilters{}, "", "", false
+	}
+
+	title := fmt.Sprintf("%s - #%s", s.env.SiteName, tag.Name)
+	link := fmt.Sprintf("%s/tags/%s", s.env.FrontendURL, url.PathEscape(tag.Name))
+	return postFilters{Tags: []string{tag.Name}}, title, link, true
+}
+
+func (s *Server) authorFeedScope(c *gin.Context) (postFilters, string, string, bool) {
+	userID := convParamToInt(c, "id")
+	if userID == 0 {
+		return postFilters{}, "", "", false
+	}
+
+	var user database.User
+	if err := s.db.Select("id", "name").First(&user, userID).Error; err != nil {
+		failFeedScope(c, err)
+		return postFilters{}, "", "", false
+	}
+
+	title := fmt.Sprintf("%s - %s", s.env.SiteName, user.Name)
+	link := fmt.Sprintf("%s/users/%d", s.env.FrontendURL, user.ID)
+	return postFilters{AuthorID: user.ID}, title, link, true
+}
+
+func failFeedScope(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.Fail(c, utils.ErrNotFound, err)
+		return
+	}
+	utils.Fail(c, utils.ErrInternal, err)
+}
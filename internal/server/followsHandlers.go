@@ -0,0 +1,355 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharon-xa/high-api/internal/database"
+	"github.com/sharon-xa/high-api/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type followResponse struct {
+	publicUserSummary
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type tagFollowResponse struct {
+	ID         uint      `json:"id"`
+	Name       string    `json:"name"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+// followCounts returns how many users follow userID, and how many userID
+// follows.
+func (s *Server) followCounts(userID uint) (followers, following int64, err error) {
+	err = s.db.Model(&database.Follow{}).
+		Joins("JOIN users ON users.id = follows.follower_id AND users.deleted_at IS NULL").
+		Where("follows.followee_id = ?", userID).
+		Count(&followers).
+		Error
+	if err != nil {
+		return 0, 0, err
+	}
+
+	err = s.db.Model(&database.Follow{}).
+		Joins("JOIN users ON users.id = follows.followee_id AND users.deleted_at IS NULL").
+		Where("follows.follower_id = ?", userID).
+		Count(&following).
+		Error
+	return followers, following, err
+}
+
+func (s *Server) followUser(c *gin.Context) {
+	claims := getAccessClaims(c)
+	if claims == nil {
+		return
+	}
+	followerID := convStrToUInt(c, claims.Subject, "user id")
+	if followerID == 0 {
+		return
+	}
+
+	followeeID := convParamToInt(c, "id")
+	if followeeID == 0 {
+		return
+	}
+	if followeeID == followerID {
+		utils.Fail(
+			c,
+			&utils.APIError{Code: http.StatusBadRequest, Message: "you can't follow yourself"},
+			nil,
+		)
+		return
+	}
+
+	var exists bool
+	err := s.db.Model(&database.User{}).
+		Select("1").
+		Where("id = ?", followeeID).
+		Limit(1).
+		Find(&exists).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+	if !exists {
+		utils.Fail(c, &utils.APIError{Code: http.StatusNotFound, Message: "user not found"}, nil)
+		return
+	}
+
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&database.Follow{FollowerID: followerID, FolloweeID: followeeID})
+	if result.Error != nil {
+		utils.Fail(c, utils.ErrInternal, result.Error)
+		return
+	}
+
+	if result.RowsAffected > 0 {
+		s.notifier.notify(notificationEvent{
+			Type:        database.NotificationFollow,
+			ActorID:     followerID,
+			RecipientID: followeeID,
+			TargetType:  database.NotificationTargetUser,
+			TargetID:    followerID,
+		})
+	}
+
+	utils.Success(c, "user followed", nil)
+}
+
+func (s *Server) unfollowUser(c *gin.Context) {
+	claims := getAccessClaims(c)
+	if claims == nil {
+		return
+	}
+	followerID := convStrToUInt(c, claims.Subject, "user id")
+	if followerID == 0 {
+		return
+	}
+
+	followeeID := convParamToInt(c, "id")
+	if followeeID == 0 {
+		return
+	}
+
+	err := s.db.Where("follower_id = ? AND followee_id = ?", followerID, followeeID).
+		Delete(&database.Follow{}).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	utils.Success(c, "user unfollowed", nil)
+}
+
+func (s *Server) getFollowers(c *gin.Context) {
+	s.listFollows(c, "follows.follower_id", "follows.followee_id")
+}
+
+func (s *Server) getFollowing(c *gin.Context) {
+	s.listFollows(c, "follows.followee_id", "follows.follower_id")
+}
+
+// listFollows pages through the users on the userColumn side of the follows
+// of the user in the "id" path parameter, who is on the ownerColumn side.
+// The most recent follows come first.
+func (s *Server) listFollows(c *gin.Context, userColumn, ownerColumn string) {
+	userID := convParamToInt(c, "id")
+	if userID == 0 {
+		return
+	}
+	after, ok := getCursor(c)
+	if !ok {
+		return
+	}
+	limit := getPageLimit(c)
+
+	var exists bool
+	err := s.db.Model(&database.User{}).
+		Select("1").
+		Where("id = ?", userID).
+		Limit(1).
+		Find(&exists).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+	if !exists {
+		utils.Fail(c, &utils.APIError{Code: http.StatusNotFound, Message: "user not found"}, nil)
+		return
+	}
+
+	q := s.db.Model(&database.Follow{}).
+		Joins("JOIN users ON users.id = "+userColumn+" AND users.deleted_at IS NULL").
+		Where(ownerColumn+" = ?", userID)
+
+	var total int64
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	page := q.Session(&gorm.Session{})
+	if after != nil {
+		page = page.Where("(follows.created_at, users.id) < (?, ?)", after.Time, after.ID)
+	}
+
+	var rows []struct {
+		ID        uint
+		Name      string
+		Image     string
+		CreatedAt time.Time
+	}
+	err = page.Select("users.id, users.name, users.image, follows.created_at").
+		Order("follows.created_at DESC, users.id DESC").
+		Limit(limit + 1).
+		Scan(&rows).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	nextCursor := ""
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[limit-1]
+		nextCursor = encodeCursor(cursor{Time: last.CreatedAt, ID: last.ID})
+	}
+
+	response := make([]followResponse, len(rows))
+	for i, r := range rows {
+		response[i] = followResponse{
+			publicUserSummary: publicUserSummary{ID: r.ID, Name: r.Name, Image: r.Image},
+			FollowedAt:        r.CreatedAt,
+		}
+	}
+
+	utils.SuccessPageWithTotal(c, "", response, nextCursor, total)
+}
+
+func (s *Server) followTag(c *gin.Context) {
+	claims := getAccessClaims(c)
+	if claims == nil {
+		return
+	}
+	userID := convStrToUInt(c, claims.Subject, "user id")
+	if userID == 0 {
+		return
+	}
+
+	tagID := convParamToInt(c, "id")
+	if tagID == 0 {
+		return
+	}
+
+	var tag database.Tag
+	if err := s.db.First(&tag, tagID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.Fail(c, &utils.APIError{Code: http.StatusNotFound, Message: "tag not found"}, err)
+			return
+		}
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	err := s.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&database.TagFollow{UserID: userID, TagID: tag.ID}).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	utils.Success(c, "tag followed", nil)
+}
+
+func (s *Server) unfollowTag(c *gin.Context) {
+	claims := getAccessClaims(c)
+	if claims == nil {
+		return
+	}
+	userID := convStrToUInt(c, claims.Subject, "user id")
+	if userID == 0 {
+		return
+	}
+
+	tagID := convParamToInt(c, "id")
+	if tagID == 0 {
+		return
+	}
+
+	err := s.db.Where("user_id = ? AND tag_id = ?", userID, tagID).
+		Delete(&database.TagFollow{}).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	utils.Success(c, "tag unfollowed", nil)
+}
+
+// getFollowedTags lists the tags the caller follows, alphabetically.
+func (s *Server) getFollowedTags(c *gin.Context) {
+	claims := getAccessClaims(c)
+	if claims == nil {
+		return
+	}
+	userID := convStrToUInt(c, claims.Subject, "user id")
+	if userID == 0 {
+		return
+	}
+
+	response := []tagFollowResponse{}
+	err := s.db.Model(&database.TagFollow{}).
+		Select("tags.id, tags.name, tag_follows.created_at AS followed_at").
+		Joins("JOIN tags ON tags.id = tag_follows.tag_id").
+		Where("tag_follows.user_id = ?", userID).
+		Order("tags.name").
+		Scan(&response).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	utils.Success(c, "", response)
+}
+
+// getFeed lists the published posts of the authors and tags the caller
+// follows, newest first. The caller's own posts are left out.
+func (s *Server) getFeed(c *gin.Context) {
+	claims := getAccessClaims(c)
+	if claims == nil {
+		return
+	}
+	userID := convStrToUInt(c, claims.Subject, "user id")
+	if userID == 0 {
+		return
+	}
+	after, ok := getCursor(c)
+	if !ok {
+		return
+	}
+
+	q := s.postListQuery(postFilters{}).
+		Where("posts.user_id <> ?", userID).
+		Where(
+			s.db.Where(
+				"posts.user_id IN (SELECT followee_id FROM follows WHERE follower_id = ?)",
+				userID,
+			).Or(
+				`EXISTS (
+					SELECT 1 FROM post_tags
+					JOIN tag_follows ON tag_follows.tag_id = post_tags.tag_id
+					WHERE post_tags.post_id = posts.id AND tag_follows.user_id = ?
+				)`,
+				userID,
+			),
+		)
+
+	posts, nextCursor, err := findPostsPage(q, after, getPageLimit(c))
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	response := make([]PostResponse, len(posts))
+	for i, p := range posts {
+		response[i] = newPostResponse(p)
+	}
+	if err := s.attachPostReactions(c, response); err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	utils.SuccessPage(c, "", response, nextCursor)
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sharon-xa/high-api/internal/auth"
@@ -37,6 +38,71 @@ func convStrToUInt(c *gin.Context, numAsStr string, fieldName string) uint {
 	return uint(val)
 }
 
+// getOptionalQueryUInt reads an optional positive integer query parameter.
+// A missing parameter yields 0; an invalid one fails the request.
+func getOptionalQueryUInt(c *gin.Context, key string) (uint, bool) {
+	val := c.Query(key)
+	if val == "" {
+		return 0, true
+	}
+
+	num := convStrToUInt(c, val, key)
+	return num, num != 0
+}
+
+// getOptionalQueryBool reads an optional boolean query parameter, returning
+// nil when it is absent.
+func getOptionalQueryBool(c *gin.Context, key string) (*bool, bool) {
+	val := c.Query(key)
+	if val == "" {
+		return nil, true
+	}
+
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		utils.Fail(
+			c,
+			&utils.APIError{Code: http.StatusBadRequest, Message: key + " must be true or false"},
+			err,
+		)
+		return nil, false
+	}
+	return &b, true
+}
+
+// getOptionalQueryTime reads an optional query parameter holding either a
+// date ("2006-01-02") or an RFC 3339 timestamp. When endOfDay is set, a bare
+// date is moved to the start of the following day so it can be used as an
+// exclusive upper bound.
+func getOptionalQueryTime(c *gin.Context, key string, endOfDay bool) (time.Time, bool) {
+	val := c.Query(key)
+	if val == "" {
+		return time.Time{}, true
+	}
+
+	if t, err := time.Parse(time.RFC3339, val); err == nil {
+		return t, true
+	}
+
+	t, err := time.Parse(time.DateOnly, val)
+	if err != nil {
+		utils.Fail(
+			c,
+			&utils.APIError{
+				Code:    http.StatusBadRequest,
+				Message: key + " must be in YYYY-MM-DD or RFC 3339 format",
+			},
+			err,
+		)
+		return time.Time{}, false
+	}
+
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, true
+}
+
 func getHeader(c *gin.Context, key string) string {
 	header := strings.TrimSpace(c.GetHeader(key))
 	if header == "" {
@@ -114,6 +180,32 @@ func getAccessClaims(c *gin.Context) *auth.AccessClaims {
 	return claims
 }
 
+// getOptionalUserID returns the ID of the authenticated caller on routes
+// where authentication is optional, or 0 for anonymous requests.
+func getOptionalUserID(c *gin.Context) uint {
+	claimsInterface, exists := c.Get("claims")
+	if !exists {
+		return 0
+	}
+
+	claims, ok := claimsInterface.(*auth.AccessClaims)
+	if !ok {
+		return 0
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil || userID < 1 {
+		return 0
+	}
+	return uint(userID)
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern, so s matches
+// literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
 func capitalizeString(s string) string {
 	if s == "" {
 		return ""
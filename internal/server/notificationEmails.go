@@ -0,0 +1,304 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/sharon-xa/high-api/internal/auth"
+	"github.com/sharon-xa/high-api/internal/database"
+	"github.com/sharon-xa/high-api/internal/mail"
+	"gorm.io/gorm"
+)
+
+const (
+	digestPeriod   = 7 * 24 * time.Hour
+	maxDigestItems = 50
+)
+
+// unsubscribeDigest is the unsubscribe link kind of digests. Other emails
+// use the type of their notification.
+const unsubscribeDigest = "digest"
+
+// notificationPreference returns the email preferences of userID, or the
+// defaults when they never set any.
+func notificationPreference(db *gorm.DB, userID uint) (database.NotificationPreference, error) {
+	var pref database.NotificationPreference
+	err := db.Where("user_id = ?", userID).Take(&pref).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return database.DefaultNotificationPreference(userID), nil
+	}
+	return pref, err
+}
+
+// unsubscribeURL is the one-click unsubscribe link of userID for kind.
+func (s *Server) unsubscribeURL(userID uint, kind string) (string, error) {
+	token, err := auth.GenerateUnsubscribeToken(
+		strconv.FormatUint(uint64(userID), 10),
+		kind,
+		s.env.TokenSecret,
+	)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(
+		"%s/notifications/unsubscribe?token=%s",
+		s.env.APIURL,
+		url.QueryEscape(token),
+	), nil
+}
+
+// notificationPosts loads the posts notifications link to, deleted ones
+// included.
+func (s *Server) notificationPosts(
+	ctx context.Context,
+	notifications []database.Notification,
+) (map[uint]database.Post, error) {
+	var ids []uint
+	for _, n := range notifications {
+		if n.PostID != nil {
+			ids = append(ids, *n.PostID)
+		}
+	}
+	posts := make(map[uint]database.Post, len(ids))
+	if len(ids) == 0 {
+		return posts, nil
+	}
+
+	var rows []database.Post
+	err := s.db.WithContext(ctx).
+		Unscoped().
+		Select("id", "title", "slug").
+		Where("id IN ?", ids).
+		Find(&rows).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range rows {
+		posts[p.ID] = p
+	}
+	return posts, nil
+}
+
+// notificationItem describes a notification for emails.
+func (s *Server) notificationItem(
+	n database.Notification,
+	posts map[uint]database.Post,
+) mail.NotificationItem {
+	item := mail.NotificationItem{Type: n.Type, Message: n.Message}
+	if n.Actor != nil {
+		item.Actor = n.Actor.Name
+	}
+	if n.PostID != nil {
+		post := posts[*n.PostID]
+		item.PostTitle = post.Title
+		item.Link = s.postURL(post)
+	}
+	if n.Type == database.NotificationFollow {
+		item.Link = fmt.Sprintf("%s/users/%d", s.env.FrontendURL, n.TargetID)
+	}
+	return item
+}
+
+// emailNotifications emails the notifications their recipients want to get
+// right away. Only verified addresses get emails.
+func (s *Server) emailNotifications(
+	ctx context.Context,
+	notifications []database.Notification,
+) error {
+	var errs []error
+	for _, n := range notifications {
+		if err := s.emailNotification(ctx, n); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	s.outbox.kick()
+	return errors.Join(errs...)
+}
+
+func (s *Server) emailNotification(ctx context.Context, n database.Notification) error {
+	db := s.db.WithContext(ctx)
+
+	pref, err := notificationPreference(db, n.UserID)
+	if err != nil {
+		return err
+	}
+	if pref.EmailMode(n.Type) != database.EmailModeImmediate {
+		return nil
+	}
+
+	var user database.User
+	err = db.Select("id", "name", "email", "verified", "language").Take(&user, n.UserID).Error
+	if err != nil {
+		return err
+	}
+	if !user.Verified {
+		return nil
+	}
+
+	posts, err := s.notificationPosts(ctx, []database.Notification{n})
+	if err != nil {
+		return err
+	}
+
+	unsubscribeURL, err := s.unsubscribeURL(user.ID, n.Type)
+	if err != nil {
+		return err
+	}
+
+	data := mail.NotificationData{
+		Layout: mail.Layout{SiteName: s.env.SiteName, UnsubscribeURL: unsubscribeURL},
+		Name:   user.Name,
+		Item:   s.notificationItem(n, posts),
+	}
+	msg, err := mail.Compose(user.Email, user.Language, mail.TemplateNotification, data)
+	if err != nil {
+		return err
+	}
+	msg.SetListUnsubscribe(unsubscribeURL)
+	return s.outbox.queue(db).Send(ctx, msg)
+}
+
+// digestNotifications is a scope limiting a notifications query to the ones
+// due in a digest: unread notifications their recipients want digested,
+// that came after their last digest, for recipients whose last digest is
+// older than due.
+func digestNotifications(due time.Time) func(*gorm.DB) *gorm.DB {
+	defaults := database.DefaultNotificationPreference(0)
+	return func(q *gorm.DB) *gorm.DB {
+		return q.
+			Joins(`LEFT JOIN notification_preferences AS prefs
+				ON prefs.user_id = notifications.user_id`).
+			Where("notifications.read_at IS NULL").
+			Where("COALESCE(prefs.weekly_digest, ?)", defaults.WeeklyDigest).
+			Where("prefs.digest_sent_at IS NULL OR prefs.digest_sent_at <= ?", due).
+			Where("notifications.created_at > COALESCE(prefs.digest_sent_at, ?)", due).
+			Where(`CASE notifications.type
+					WHEN ? THEN COALESCE(prefs.comment, ?)
+					WHEN ? THEN COALESCE(prefs.reply, ?)
+					WHEN ? THEN COALESCE(prefs.follow, ?)
+				END = ?`,
+				database.NotificationComment, defaults.Comment,
+				database.NotificationReply, defaults.Reply,
+				database.NotificationFollow, defaults.Follow,
+				database.EmailModeDigest,
+			)
+	}
+}
+
+// sendDigests emails the weekly digest of every user who has one due.
+func (s *Server) sendDigests(ctx context.Context) error {
+	due := time.Now().Add(-digestPeriod)
+
+	var userIDs []uint
+	err := s.db.WithContext(ctx).
+		Model(&database.Notification{}).
+		Scopes(digestNotifications(due)).
+		Distinct().
+		Pluck("notifications.user_id", &userIDs).
+		Error
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, userID := range userIDs {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := s.sendDigest(ctx, userID, due); err != nil {
+			errs = append(errs, fmt.Errorf("digest of user %d: %w", userID, err))
+		}
+	}
+	s.outbox.kick()
+	return errors.Join(errs...)
+}
+
+// sendDigest queues the digest of userID. The digest is marked as sent in
+// the same transaction, so it is neither lost nor queued twice.
+func (s *Server) sendDigest(ctx context.Context, userID uint, due time.Time) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user database.User
+		err := tx.Select("id", "name", "email", "verified", "language").
+			Take(&user, userID).
+			Error
+		if err != nil {
+			return err
+		}
+
+		if user.Verified {
+			if err := s.queueDigest(ctx, tx, user, due); err != nil {
+				return err
+			}
+		}
+
+		// Unverified users are marked as well, so they aren't picked again
+		// until next week.
+		pref, err := notificationPreference(tx, userID)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		pref.DigestSentAt = &now
+		return tx.Save(&pref).Error
+	})
+}
+
+func (s *Server) queueDigest(
+	ctx context.Context,
+	tx *gorm.DB,
+	user database.User,
+	due time.Time,
+) error {
+	q := tx.Model(&database.Notification{}).
+		Scopes(digestNotifications(due)).
+		Where("notifications.user_id = ?", user.ID)
+
+	var total int64
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return err
+	}
+
+	var notifications []database.Notification
+	err := q.Session(&gorm.Session{}).
+		Preload("Actor", func(db *gorm.DB) *gorm.DB { return db.Select("id", "name") }).
+		Order("notifications.created_at DESC").
+		Limit(maxDigestItems).
+		Find(&notifications).
+		Error
+	if err != nil {
+		return err
+	}
+
+	posts, err := s.notificationPosts(ctx, notifications)
+	if err != nil {
+		return err
+	}
+
+	items := make([]mail.NotificationItem, len(notifications))
+	for i, n := range notifications {
+		items[i] = s.notificationItem(n, posts)
+	}
+
+	unsubscribeURL, err := s.unsubscribeURL(user.ID, unsubscribeDigest)
+	if err != nil {
+		return err
+	}
+
+	data := mail.DigestData{
+		Layout: mail.Layout{SiteName: s.env.SiteName, UnsubscribeURL: unsubscribeURL},
+		Name:   user.Name,
+		Items:  items,
+		More:   total - int64(len(notifications)),
+	}
+	msg, err := mail.Compose(user.Email, user.Language, mail.TemplateDigest, data)
+	if err != nil {
+		return err
+	}
+	msg.SetListUnsubscribe(unsubscribeURL)
+	return s.outbox.queue(tx).Send(ctx, msg)
+}
@@ -0,0 +1,308 @@
+package server
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharon-xa/high-api/internal/auth"
+	"github.com/sharon-xa/high-api/internal/database"
+	"github.com/sharon-xa/high-api/internal/utils"
+)
+
+type notificationPreferencesResponse struct {
+	Comment      string `json:"comment"`
+	Reply        string `json:"reply"`
+	Follow       string `json:"follow"`
+	WeeklyDigest bool   `json:"weekly_digest"`
+}
+
+func newNotificationPreferencesResponse(
+	pref database.NotificationPreference,
+) notificationPreferencesResponse {
+	return notificationPreferencesResponse{
+		Comment:      pref.Comment,
+		Reply:        pref.Reply,
+		Follow:       pref.Follow,
+		WeeklyDigest: pref.WeeklyDigest,
+	}
+}
+
+func (s *Server) getNotificationPreferences(c *gin.Context) {
+	claims := getAccessClaims(c)
+	if claims == nil {
+		return
+	}
+	userID := convStrToUInt(c, claims.Subject, "user id")
+	if userID == 0 {
+		return
+	}
+
+	pref, err := notificationPreference(s.db, userID)
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	utils.Success(c, "", newNotificationPreferencesResponse(pref))
+}
+
+// updateNotificationPreferencesReq leaves the preferences it doesn't name
+// as they are.
+type updateNotificationPreferencesReq struct {
+	Comment      *string `json:"comment"`
+	Reply        *string `json:"reply"`
+	Follow       *string `json:"follow"`
+	WeeklyDigest *bool   `json:"weekly_digest"`
+}
+
+func validEmailMode(mode string) bool {
+	switch mode {
+	case database.EmailModeImmediate, database.EmailModeDigest, database.EmailModeOff:
+		return true
+	}
+	return false
+}
+
+// turnOffDigestModes turns off the notifications pref sends to the digest
+// when the digest itself is off, they would never be emailed otherwise.
+func turnOffDigestModes(pref *database.NotificationPreference) {
+	if pref.WeeklyDigest {
+		return
+	}
+	for _, mode := range []*string{&pref.Comment, &pref.Reply, &pref.Follow} {
+		if *mode == database.EmailModeDigest {
+			*mode = database.EmailModeOff
+		}
+	}
+}
+
+func (s *Server) updateNotificationPreferences(c *gin.Context) {
+	claims := getAccessClaims(c)
+	if claims == nil {
+		return
+	}
+	userID := convStrToUInt(c, claims.Subject, "user id")
+	if userID == 0 {
+		return
+	}
+
+	var req updateNotificationPreferencesReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+
+	for _, mode := range []*string{req.Comment, req.Reply, req.Follow} {
+		if mode != nil && !validEmailMode(*mode) {
+			utils.Fail(
+				c,
+				&utils.APIError{
+					Code:    http.StatusBadRequest,
+					Message: "email mode must be one of immediate, digest or off",
+				},
+				nil,
+			)
+			return
+		}
+	}
+
+	digested := false
+	for _, mode := range []*string{req.Comment, req.Reply, req.Follow} {
+		if mode != nil && *mode == database.EmailModeDigest {
+			digested = true
+		}
+	}
+	if digested && req.WeeklyDigest != nil && !*req.WeeklyDigest {
+		utils.Fail(
+			c,
+			&utils.APIError{
+				Code:    http.StatusBadRequest,
+				Message: "digest email modes need the weekly digest on",
+			},
+			nil,
+		)
+		return
+	}
+
+	pref, err := notificationPreference(s.db, userID)
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	if req.Comment != nil {
+		pref.Comment = *req.Comment
+	}
+	if req.Reply != nil {
+		pref.Reply = *req.Reply
+	}
+	if req.Follow != nil {
+		pref.Follow = *req.Follow
+	}
+	if req.WeeklyDigest != nil {
+		pref.WeeklyDigest = *req.WeeklyDigest
+	}
+	// Choosing the digest for some notifications turns it on, turning it off
+	// turns those notifications off.
+	if digested {
+		pref.WeeklyDigest = true
+	}
+	turnOffDigestModes(&pref)
+
+	if err := s.db.Save(&pref).Error; err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	utils.Success(
+		c,
+		"notification preferences updated",
+		newNotificationPreferencesResponse(pref),
+	)
+}
+
+// unsubscribePage is the page unsubscribe links open on. It only asks for
+// a confirmation, since link scanners follow the links of the emails they
+// check.
+const unsubscribePage = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Unsubscribe</title></head>
+<body>
+	<p>%s</p>
+	%s
+</body>
+</html>`
+
+func unsubscribeHTML(c *gin.Context, code int, message, form string) {
+	body := fmt.Sprintf(unsubscribePage, html.EscapeString(message), form)
+	c.Data(code, "text/html; charset=utf-8", []byte(body))
+}
+
+// parseUnsubscribeToken reads the token of an unsubscribe link, answering
+// for itself when it can't.
+func (s *Server) parseUnsubscribeToken(c *gin.Context) (*auth.UnsubscribeClaims, uint) {
+	claims, err := auth.ParseUnsubscribeToken(c.Query("token"), s.env.TokenSecret)
+	if err != nil {
+		unsubscribeHTML(c, http.StatusBadRequest, "This unsubscribe link is invalid.", "")
+		return nil, 0
+	}
+
+	var userID uint
+	if _, err := fmt.Sscan(claims.Subject, &userID); err != nil || userID == 0 {
+		unsubscribeHTML(c, http.StatusBadRequest, "This unsubscribe link is invalid.", "")
+		return nil, 0
+	}
+	return claims, userID
+}
+
+// unsubscribeDescription names the emails of an unsubscribe link kind.
+func unsubscribeDescription(kind string) string {
+	switch kind {
+	case database.NotificationComment:
+		return "comments on your posts"
+	case database.NotificationReply:
+		return "replies to your comments"
+	case database.NotificationFollow:
+		return "new followers"
+	case unsubscribeDigest:
+		return "the weekly digest"
+	}
+	return ""
+}
+
+// getUnsubscribe shows the confirmation of an unsubscribe link.
+func (s *Server) getUnsubscribe(c *gin.Context) {
+	claims, userID := s.parseUnsubscribeToken(c)
+	if claims == nil || userID == 0 {
+		return
+	}
+
+	description := unsubscribeDescription(claims.Kind)
+	if description == "" {
+		unsubscribeHTML(c, http.StatusBadRequest, "This unsubscribe link is invalid.", "")
+		return
+	}
+
+	form := fmt.Sprintf(
+		`<form method="post" action="?token=%s">
+		<button type="submit">Unsubscribe</button>
+	</form>`,
+		html.EscapeString(url.QueryEscape(c.Query("token"))),
+	)
+	unsubscribeHTML(
+		c,
+		http.StatusOK,
+		fmt.Sprintf("Stop getting emails about %s?", description),
+		form,
+	)
+}
+
+// unsubscribe turns off the emails of an unsubscribe link. Mail clients
+// post to it directly for one-click unsubscribes (RFC 8058), so it works
+// without logging in: the signed token is all it needs.
+func (s *Server) unsubscribe(c *gin.Context) {
+	claims, userID := s.parseUnsubscribeToken(c)
+	if claims == nil || userID == 0 {
+		return
+	}
+
+	description := unsubscribeDescription(claims.Kind)
+	if description == "" {
+		unsubscribeHTML(c, http.StatusBadRequest, "This unsubscribe link is invalid.", "")
+		return
+	}
+
+	pref, err := notificationPreference(s.db, userID)
+	if err != nil {
+		unsubscribeHTML(
+			c,
+			http.StatusInternalServerError,
+			"Something went wrong, please try again later.",
+			"",
+		)
+		return
+	}
+
+	switch claims.Kind {
+	case database.NotificationComment:
+		pref.Comment = database.EmailModeOff
+	case database.NotificationReply:
+		pref.Reply = database.EmailModeOff
+	case database.NotificationFollow:
+		pref.Follow = database.EmailModeOff
+	case unsubscribeDigest:
+		pref.WeeklyDigest = false
+		turnOffDigestModes(&pref)
+	}
+
+	// The user may be gone since the email was sent.
+	var exists bool
+	err = s.db.Model(&database.User{}).
+		Select("1").
+		Where("id = ?", userID).
+		Limit(1).
+		Find(&exists).
+		Error
+	if err == nil && exists {
+		err = s.db.Save(&pref).Error
+	}
+	if err != nil {
+		unsubscribeHTML(
+			c,
+			http.StatusInternalServerError,
+			"Something went wrong, please try again later.",
+			"",
+		)
+		return
+	}
+
+	unsubscribeHTML(
+		c,
+		http.StatusOK,
+		fmt.Sprintf("You won't get emails about %s anymore.", description),
+		"",
+	)
+}
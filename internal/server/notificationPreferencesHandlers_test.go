@@ -0,0 +1,51 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/sharon-xa/high-api/internal/database"
+)
+
+func TestTurnOffDigestModes(t *testing.T) {
+	const (
+		immediate = database.EmailModeImmediate
+		digest    = database.EmailModeDigest
+		off       = database.EmailModeOff
+	)
+	tests := []struct {
+		name string
+		pref database.NotificationPreference
+		want database.NotificationPreference
+	}{
+		{
+			name: "digest on",
+			pref: database.NotificationPreference{
+				Comment: digest, Reply: immediate, Follow: digest, WeeklyDigest: true,
+			},
+			want: database.NotificationPreference{
+				Comment: digest, Reply: immediate, Follow: digest, WeeklyDigest: true,
+			},
+		},
+		{
+			name: "digest off",
+			pref: database.NotificationPreference{
+				Comment: digest, Reply: immediate, Follow: digest,
+			},
+			want: database.NotificationPreference{
+				Comment: off, Reply: immediate, Follow: off,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pref := tt.pref
+			turnOffDigestModes(&pref)
+			got := newNotificationPreferencesResponse(pref)
+			want := newNotificationPreferencesResponse(tt.want)
+			if got != want {
+				t.Errorf("turnOffDigestModes() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
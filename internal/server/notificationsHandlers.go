@@ -0,0 +1,393 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharon-xa/high-api/internal/auth"
+	"github.com/sharon-xa/high-api/internal/database"
+	"github.com/sharon-xa/high-api/internal/utils"
+	"gorm.io/gorm"
+)
+
+// notificationHeartbeat keeps idle streams from being cut by proxies.
+const notificationHeartbeat = 30 * time.Second
+
+type notificationResponse struct {
+	ID         uint               `json:"id"`
+	Type       string             `json:"type"`
+	Actor      *publicUserSummary `json:"actor"`
+	TargetType string             `json:"target_type"`
+	TargetID   uint               `json:"target_id"`
+	PostID     *uint              `json:"post_id,omitempty"`
+	Message    string             `json:"message,omitempty"`
+	Read       bool               `json:"read"`
+	CreatedAt  time.Time          `json:"created_at"`
+}
+
+func newNotificationResponse(n database.Notification) notificationResponse {
+	response := notificationResponse{
+		ID:         n.ID,
+		Type:       n.Type,
+		TargetType: n.TargetType,
+		TargetID:   n.TargetID,
+		PostID:     n.PostID,
+		Message:    n.Message,
+		Read:       n.ReadAt != nil,
+		CreatedAt:  n.CreatedAt,
+	}
+	if n.Actor != nil {
+		response.Actor = &publicUserSummary{
+			ID:    n.Actor.ID,
+			Name:  n.Actor.Name,
+			Image: n.Actor.Image,
+		}
+	}
+	return response
+}
+
+// notifyModeration tells the author of a post or a comment that a moderator
+// acted on it. Moderators stay anonymous.
+func (s *Server) notifyModeration(
+	authorID uint,
+	targetType string,
+	targetID uint,
+	postID *uint,
+	message string,
+) {
+	s.notifier.notify(notificationEvent{
+		Type:        database.NotificationModeration,
+		RecipientID: authorID,
+		TargetType:  targetType,
+		TargetID:    targetID,
+		PostID:      postID,
+		Message:     message,
+	})
+}
+
+type unreadNotificationsResponse struct {
+	Unread int64 `json:"unread"`
+}
+
+func (s *Server) countUnreadNotifications(userID uint) (int64, error) {
+	var unread int64
+	err := s.db.Model(&database.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Count(&unread).
+		Error
+	return unread, err
+}
+
+// publishUnreadCount keeps the other open streams of a user in step after
+// they read notifications.
+func (s *Server) publishUnreadCount(userID uint) {
+	unread, err := s.countUnreadNotifications(userID)
+	if err != nil {
+		log.Printf("Notifier: counting unread notifications failed: %v\n", err)
+		return
+	}
+	s.hub.publish(userID, streamMessage{
+		Event: "unread",
+		Data:  unreadNotificationsResponse{Unread: unread},
+	})
+}
+
+// getNotifications lists the caller's notifications, newest first. The
+// "unread" query parameter narrows the list to unread ones.
+func (s *Server) getNotifications(c *gin.Context) {
+	claims := getAccessClaims(c)
+	if claims == nil {
+		return
+	}
+	userID := convStrToUInt(c, claims.Subject, "user id")
+	if userID == 0 {
+		return
+	}
+
+	unread, ok := getOptionalQueryBool(c, "unread")
+	if !ok {
+		return
+	}
+	after, ok := getCursor(c)
+	if !ok {
+		return
+	}
+	limit := getPageLimit(c)
+
+	q := s.db.Model(&database.Notification{}).Where("notifications.user_id = ?", userID)
+	if unread != nil && *unread {
+		q = q.Where("notifications.read_at IS NULL")
+	} else if unread != nil {
+		q = q.Where("notifications.read_at IS NOT NULL")
+	}
+
+	var total int64
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	page := q.Session(&gorm.Session{}).
+		Preload("Actor", func(db *gorm.DB) *gorm.DB { return db.Select("id", "name", "image") })
+	if after != nil {
+		page = page.Where(
+			"(notifications.created_at, notifications.id) < (?, ?)",
+			after.Time,
+			after.ID,
+		)
+	}
+
+	var notifications []database.Notification
+	err := page.Order("notifications.created_at DESC, notifications.id DESC").
+		Limit(limit + 1).
+		Find(&notifications).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	nextCursor := ""
+	if len(notifications) > limit {
+		notifications = notifications[:limit]
+		last := notifications[limit-1]
+		nextCursor = encodeCursor(cursor{Time: last.CreatedAt, ID: last.ID})
+	}
+
+	response := make([]notificationResponse, len(notifications))
+	for i, n := range notifications {
+		response[i] = newNotificationResponse(n)
+	}
+
+	utils.SuccessPageWithTotal(c, "", response, nextCursor, total)
+}
+
+func (s *Server) readNotification(c *gin.Context) {
+	claims := getAccessClaims(c)
+	if claims == nil {
+		return
+	}
+	userID := convStrToUInt(c, claims.Subject, "user id")
+	if userID == 0 {
+		return
+	}
+
+	notificationID := convParamToInt(c, "id")
+	if notificationID == 0 {
+		return
+	}
+
+	var notification database.Notification
+	err := s.db.Select("id", "read_at").
+		Where("user_id = ?", userID).
+		Take(&notification, notificationID).
+		Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.Fail(
+				c,
+				&utils.APIError{Code: http.StatusNotFound, Message: "notification not found"},
+				err,
+			)
+			return
+		}
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	if notification.ReadAt == nil {
+		err := s.db.Model(&notification).Update("read_at", time.Now()).Error
+		if err != nil {
+			utils.Fail(c, utils.ErrInternal, err)
+			return
+		}
+		s.publishUnreadCount(userID)
+	}
+
+	utils.Success(c, "notification marked as read", nil)
+}
+
+type readAllNotificationsResponse struct {
+	Read int64 `json:"read"`
+}
+
+func (s *Server) readAllNotifications(c *gin.Context) {
+	claims := getAccessClaims(c)
+	if claims == nil {
+		return
+	}
+	userID := convStrToUInt(c, claims.Subject, "user id")
+	if userID == 0 {
+		return
+	}
+
+	result := s.db.Model(&database.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		utils.Fail(c, utils.ErrInternal, result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		s.publishUnreadCount(userID)
+	}
+
+	utils.Success(
+		c,
+		"notifications marked as read",
+		readAllNotificationsResponse{Read: result.RowsAffected},
+	)
+}
+
+type streamTokenResponse struct {
+	Token string `json:"token"`
+}
+
+// getStreamToken hands out the token that opens the notification stream
+// from a browser, see auth.GenerateStreamToken.
+func (s *Server) getStreamToken(c *gin.Context) {
+	claims := getAccessClaims(c)
+	if claims == nil {
+		return
+	}
+
+	token, err := auth.GenerateStreamToken(claims.Subject, s.env.TokenSecret)
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	utils.Success(c, "", streamTokenResponse{Token: token})
+}
+
+// streamUserID authenticates the caller of the notification stream, with
+// the "token" query parameter when there is one and the Authorization
+// header otherwise. It returns 0 once it responded.
+func (s *Server) streamUserID(c *gin.Context) uint {
+	var subject string
+	if token := c.Query("token"); token != "" {
+		claims, err := auth.ParseStreamToken(token, s.env.TokenSecret)
+		if err != nil {
+			utils.Fail(c, utils.ErrInvalidToken, nil)
+			return 0
+		}
+		subject = claims.Subject
+	} else {
+		claims := auth.GetAccessClaimsFromAuthHeader(c, s.env.AccessTokenSecret)
+		if claims == nil {
+			return 0
+		}
+		subject = claims.Subject
+	}
+
+	userID := convStrToUInt(c, subject, "user id")
+	if userID == 0 {
+		return 0
+	}
+
+	var user database.User
+	err := s.db.Select("id", "banned").Take(&user, userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.Fail(c, utils.ErrInvalidToken, nil)
+		return 0
+	}
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return 0
+	}
+	if !s.rejectBanned(c, &user) {
+		return 0
+	}
+
+	return userID
+}
+
+// mayKeepStream tells whether userID still exists and isn't banned.
+func (s *Server) mayKeepStream(userID uint) (bool, error) {
+	var user database.User
+	err := s.db.Select("id", "banned").Take(&user, userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	ban, err := currentBan(s.db, &user)
+	return ban == nil, err
+}
+
+// streamNotifications pushes the caller's new notifications as server-sent
+// events, starting with their unread count. "notification" events carry a
+// notification, "unread" events the unread count.
+func (s *Server) streamNotifications(c *gin.Context) {
+	userID := s.streamUserID(c)
+	if userID == 0 {
+		return
+	}
+
+	unread, err := s.countUnreadNotifications(userID)
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	messages, unsubscribe := s.hub.subscribe(userID)
+	defer unsubscribe()
+	if messages == nil {
+		utils.Fail(
+			c,
+			&utils.APIError{Code: http.StatusServiceUnavailable, Message: "server is shutting down"},
+			nil,
+		)
+		return
+	}
+
+	// Streams stay open far longer than the server's write timeout.
+	err = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.SSEvent("unread", unreadNotificationsResponse{Unread: unread})
+
+	heartbeat := time.NewTicker(notificationHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case msg, ok := <-messages:
+			if !ok {
+				return false
+			}
+			c.SSEvent(msg.Event, msg.Data)
+			return true
+		case <-heartbeat.C:
+			// The ban check only runs when the stream opens otherwise.
+			allowed, err := s.mayKeepStream(userID)
+			if err != nil {
+				log.Printf(
+					"Notifications: checking the stream of user %d failed: %v\n",
+					userID,
+					err,
+				)
+			} else if !allowed {
+				return false
+			}
+			_, err = fmt.Fprint(w, ": ping\n\n")
+			return err == nil
+		}
+	})
+}
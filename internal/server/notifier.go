@@ -0,0 +1,287 @@
+package server
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sharon-xa/high-api/internal/database"
+	"gorm.io/gorm"
+)
+
+const (
+	notificationQueueSize  = 256
+	notificationStreamSize = 16
+	// followNotificationWindow is how long a follow notification stands for
+	// the same follower, so that unfollowing and following again doesn't
+	// notify over and over.
+	followNotificationWindow = 24 * time.Hour
+)
+
+// notificationEvent is something that happened which may concern other
+// users. Comment events name the new comment, the notifier works out whom
+// to tell, the other events name their RecipientID.
+type notificationEvent struct {
+	Type        string
+	ActorID     uint
+	RecipientID uint
+	TargetType  string
+	TargetID    uint
+	PostID      *uint
+	Message     string
+}
+
+// notifier turns events into notifications off the request path: handlers
+// queue events, and a background worker works out the recipients, stores
+// the notifications, pushes them to the recipients' open streams and hands
+// them to email.
+type notifier struct {
+	db     *gorm.DB
+	hub    *notificationHub
+	email  func(ctx context.Context, notifications []database.Notification) error
+	events chan notificationEvent
+}
+
+func newNotifier(
+	db *gorm.DB,
+	hub *notificationHub,
+	email func(ctx context.Context, notifications []database.Notification) error,
+) *notifier {
+	return &notifier{
+		db:     db,
+		hub:    hub,
+		email:  email,
+		events: make(chan notificationEvent, notificationQueueSize),
+	}
+}
+
+// notify queues e without blocking. Events that don't fit in the queue are
+// dropped, notifications aren't worth slowing requests down for.
+func (n *notifier) notify(e notificationEvent) {
+	select {
+	case n.events <- e:
+	default:
+		log.Printf("Notifier: queue is full, dropping a %s notification\n", e.Type)
+	}
+}
+
+// run delivers the queued events until ctx is cancelled, then closes the
+// streams.
+func (n *notifier) run(ctx context.Context) {
+	defer n.hub.close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-n.events:
+			if err := n.deliver(ctx, e); err != nil && ctx.Err() == nil {
+				log.Printf("Notifier: %s notification failed: %v\n", e.Type, err)
+			}
+		}
+	}
+}
+
+func (n *notifier) deliver(ctx context.Context, e notificationEvent) error {
+	notifications, err := n.expand(ctx, e)
+	if err != nil || len(notifications) == 0 {
+		return err
+	}
+
+	if err := n.db.WithContext(ctx).Create(&notifications).Error; err != nil {
+		return err
+	}
+
+	var actor database.User
+	if e.ActorID != 0 {
+		err := n.db.WithContext(ctx).Select("id", "name", "image").Find(&actor, e.ActorID).Error
+		if err != nil {
+			return err
+		}
+	}
+	for i := range notifications {
+		if actor.ID != 0 {
+			notifications[i].Actor = &actor
+		}
+		n.hub.publish(notifications[i].UserID, streamMessage{
+			Event: "notification",
+			Data:  newNotificationResponse(notifications[i]),
+		})
+	}
+	return n.email(ctx, notifications)
+}
+
+// expand turns e into the notifications of its recipients. Nobody is
+// notified of their own doing.
+func (n *notifier) expand(
+	ctx context.Context,
+	e notificationEvent,
+) ([]database.Notification, error) {
+	var actorID *uint
+	if e.ActorID != 0 {
+		actorID = &e.ActorID
+	}
+
+	if e.Type != database.NotificationComment {
+		if e.RecipientID == 0 || e.RecipientID == e.ActorID {
+			return nil, nil
+		}
+		if e.Type == database.NotificationFollow {
+			notified, err := n.notifiedOfFollow(ctx, e)
+			if err != nil || notified {
+				return nil, err
+			}
+		}
+		return []database.Notification{{
+			UserID:     e.RecipientID,
+			ActorID:    actorID,
+			Type:       e.Type,
+			TargetType: e.TargetType,
+			TargetID:   e.TargetID,
+			PostID:     e.PostID,
+			Message:    e.Message,
+		}}, nil
+	}
+
+	var comment struct {
+		PostID       uint
+		PostAuthor   uint
+		ParentAuthor *uint
+	}
+	err := n.db.WithContext(ctx).
+		Table("comments").
+		Select("comments.post_id, posts.user_id AS post_author, parents.user_id AS parent_author").
+		Joins("JOIN posts ON posts.id = comments.post_id").
+		Joins("LEFT JOIN comments AS parents ON parents.id = comments.parent_id").
+		Where("comments.id = ? AND comments.deleted_at IS NULL", e.TargetID).
+		Scan(&comment).
+		Error
+	if err != nil || comment.PostID == 0 {
+		return nil, err
+	}
+
+	notification := database.Notification{
+		ActorID:    actorID,
+		TargetType: database.NotificationTargetComment,
+		TargetID:   e.TargetID,
+		PostID:     &comment.PostID,
+	}
+
+	var notifications []database.Notification
+	if comment.ParentAuthor != nil && *comment.ParentAuthor != e.ActorID {
+		reply := notification
+		reply.UserID = *comment.ParentAuthor
+		reply.Type = database.NotificationReply
+		notifications = append(notifications, reply)
+	}
+	// Post authors replied to on their own post only hear about the reply.
+	if comment.PostAuthor != e.ActorID &&
+		(comment.ParentAuthor == nil || *comment.ParentAuthor != comment.PostAuthor) {
+		onPost := notification
+		onPost.UserID = comment.PostAuthor
+		onPost.Type = database.NotificationComment
+		notifications = append(notifications, onPost)
+	}
+	return notifications, nil
+}
+
+// notifiedOfFollow reports whether the recipient of a follow event heard
+// about the same follower within followNotificationWindow. Events are
+// delivered one at a time, so there is no race between the check and the
+// insert.
+func (n *notifier) notifiedOfFollow(ctx context.Context, e notificationEvent) (bool, error) {
+	var exists bool
+	err := n.db.WithContext(ctx).
+		Model(&database.Notification{}).
+		Select("1").
+		Where(
+			"user_id = ? AND actor_id = ? AND type = ? AND created_at > ?",
+			e.RecipientID,
+			e.ActorID,
+			database.NotificationFollow,
+			time.Now().Add(-followNotificationWindow),
+		).
+		Limit(1).
+		Find(&exists).
+		Error
+	return exists, err
+}
+
+// streamMessage is one server-sent event.
+type streamMessage struct {
+	Event string
+	Data  any
+}
+
+// notificationHub fans messages out to the open notification streams of
+// each user. It only knows about the streams of this process.
+type notificationHub struct {
+	mu      sync.Mutex
+	streams map[uint]map[chan streamMessage]struct{}
+	closed  bool
+}
+
+func newNotificationHub() *notificationHub {
+	return &notificationHub{streams: map[uint]map[chan streamMessage]struct{}{}}
+}
+
+// subscribe opens a stream for userID. The channel is closed once the hub
+// is, it is nil when the hub already is closed.
+func (h *notificationHub) subscribe(userID uint) (<-chan streamMessage, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, func() {}
+	}
+
+	ch := make(chan streamMessage, notificationStreamSize)
+	if h.streams[userID] == nil {
+		h.streams[userID] = map[chan streamMessage]struct{}{}
+	}
+	h.streams[userID][ch] = struct{}{}
+
+	unsubscribe := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		if _, ok := h.streams[userID][ch]; !ok {
+			return
+		}
+		delete(h.streams[userID], ch)
+		if len(h.streams[userID]) == 0 {
+			delete(h.streams, userID)
+		}
+		close(ch)
+	}
+	return ch, unsubscribe
+}
+
+// publish sends msg to every stream of userID. Streams too slow to keep up
+// miss it, clients catch up through the notifications list.
+func (h *notificationHub) publish(userID uint, msg streamMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.streams[userID] {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
+
+// close ends every stream, and keeps new ones from opening.
+func (h *notificationHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for userID, streams := range h.streams {
+		for ch := range streams {
+			close(ch)
+		}
+		delete(h.streams, userID)
+	}
+}
@@ -0,0 +1,259 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sharon-xa/high-api/internal/database"
+	"github.com/sharon-xa/high-api/internal/mail"
+	"gorm.io/gorm"
+)
+
+const (
+	outboxWorkers      = 4
+	outboxPollInterval = 5 * time.Second
+	// outboxLease is how long a worker has to send an email before others
+	// may take it over. It has to outlast the mailer's own timeout.
+	outboxLease = 2 * time.Minute
+	// outboxMaxAttempts is how many times an email is tried before it is
+	// dead-lettered.
+	outboxMaxAttempts = 8
+	outboxBaseBackoff = 30 * time.Second
+	outboxMaxBackoff  = 6 * time.Hour
+	// outboxRetention is how long the bodies of sent emails are kept. They
+	// hold one-time passwords and reset links, the rest of the row stays for
+	// the admin list.
+	outboxRetention = 24 * time.Hour
+)
+
+// outbox sends the emails of the outbound_emails table. Emails are sent at
+// least once: an email whose worker dies mid-send, or whose sent status
+// fails to save, is sent again once its lease runs out. Emails must be
+// fine to get twice, so nothing queued may rely on being delivered once.
+type outbox struct {
+	db     *gorm.DB
+	mailer mail.Mailer
+
+	wake chan struct{}
+	stop chan struct{}
+	done sync.WaitGroup
+
+	// sending is the context of the emails being sent, it is cancelled
+	// when shutdown runs out of time.
+	sending     context.Context
+	cancelSends context.CancelFunc
+}
+
+func newOutbox(db *gorm.DB, mailer mail.Mailer) *outbox {
+	sending, cancelSends := context.WithCancel(context.Background())
+	return &outbox{
+		db:          db,
+		mailer:      mailer,
+		wake:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		sending:     sending,
+		cancelSends: cancelSends,
+	}
+}
+
+// queue returns a mailer writing emails to the outbox through db, which is
+// usually the transaction of what causes them. Call kick once it commits.
+func (o *outbox) queue(db *gorm.DB) mail.Mailer {
+	return outboxMailer{db: db}
+}
+
+type outboxMailer struct {
+	db *gorm.DB
+}
+
+func (m outboxMailer) Send(ctx context.Context, msg mail.Message) error {
+	email := database.OutboundEmail{
+		To:            msg.To,
+		Subject:       msg.Subject,
+		HTML:          msg.HTML,
+		Text:          msg.Text,
+		Status:        database.OutboundEmailPending,
+		NextAttemptAt: time.Now(),
+	}
+	if len(msg.Headers) > 0 {
+		headers, err := json.Marshal(msg.Headers)
+		if err != nil {
+			return err
+		}
+		email.Headers = headers
+	}
+	return m.db.WithContext(ctx).Create(&email).Error
+}
+
+// kick lets a worker know there are emails to send, rather than waiting for
+// the next poll.
+func (o *outbox) kick() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// start runs the workers until shutdown is called.
+func (o *outbox) start() {
+	for range outboxWorkers {
+		o.done.Add(1)
+		go o.work()
+	}
+}
+
+// shutdown stops the workers and waits for the emails being sent, or for
+// ctx to end, which cuts the sends short. Emails left mid-send are sent
+// again on the next start.
+func (o *outbox) shutdown(ctx context.Context) error {
+	close(o.stop)
+
+	drained := make(chan struct{})
+	go func() {
+		o.done.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		o.cancelSends()
+		return ctx.Err()
+	}
+}
+
+func (o *outbox) work() {
+	defer o.done.Done()
+
+	poll := time.NewTicker(outboxPollInterval)
+	defer poll.Stop()
+
+	for {
+		// Emails are sent one after the other until none is due.
+		for {
+			select {
+			case <-o.stop:
+				return
+			default:
+			}
+
+			sent, err := o.sendNext()
+			if err != nil {
+				log.Printf("Outbox: %v\n", err)
+			}
+			if !sent {
+				break
+			}
+		}
+
+		select {
+		case <-o.stop:
+			return
+		case <-o.wake:
+		case <-poll.C:
+		}
+	}
+}
+
+// sendNext sends the next due email, if any. It reports whether there was
+// one.
+func (o *outbox) sendNext() (bool, error) {
+	email, err := o.claim()
+	if err != nil || email == nil {
+		return false, err
+	}
+
+	msg := mail.Message{
+		To:      email.To,
+		Subject: email.Subject,
+		HTML:    email.HTML,
+		Text:    email.Text,
+	}
+	if len(email.Headers) > 0 {
+		if err := json.Unmarshal(email.Headers, &msg.Headers); err != nil {
+			return true, o.fail(email, err)
+		}
+	}
+
+	// In-flight emails are let finish during shutdown, until its context
+	// ends.
+	if err := o.mailer.Send(o.sending, msg); err != nil {
+		return true, o.fail(email, err)
+	}
+
+	return true, o.db.Model(email).Updates(map[string]any{
+		"status":     database.OutboundEmailSent,
+		"sent_at":    time.Now(),
+		"last_error": "",
+	}).Error
+}
+
+// claim takes the next due email and leases it, so that no other worker
+// sends it meanwhile.
+func (o *outbox) claim() (*database.OutboundEmail, error) {
+	var emails []database.OutboundEmail
+	now := time.Now()
+	err := o.db.Raw(
+		`UPDATE outbound_emails
+		SET attempts = attempts + 1, next_attempt_at = ?, updated_at = ?
+		WHERE id = (
+			SELECT id FROM outbound_emails
+			WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY next_attempt_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		now.Add(outboxLease),
+		now,
+		database.OutboundEmailPending,
+		now,
+	).Scan(&emails).Error
+	if err != nil || len(emails) == 0 {
+		return nil, err
+	}
+	return &emails[0], nil
+}
+
+// fail records a failed attempt at email, and schedules the next one with
+// an exponential backoff. Emails out of attempts are dead-lettered.
+func (o *outbox) fail(email *database.OutboundEmail, sendErr error) error {
+	log.Printf("Outbox: email %d failed (attempt %d): %v\n", email.ID, email.Attempts, sendErr)
+
+	updates := map[string]any{"last_error": sendErr.Error()}
+	if email.Attempts >= outboxMaxAttempts {
+		updates["status"] = database.OutboundEmailDead
+	} else {
+		updates["next_attempt_at"] = time.Now().Add(outboxBackoff(email.Attempts))
+	}
+	return o.db.Model(email).Updates(updates).Error
+}
+
+// pruneSent blanks the bodies of the emails sent more than outboxRetention
+// ago.
+func (o *outbox) pruneSent(ctx context.Context) error {
+	return o.db.WithContext(ctx).
+		Model(&database.OutboundEmail{}).
+		Where("status = ? AND sent_at < ? AND (html <> '' OR text <> '' OR headers IS NOT NULL)",
+			database.OutboundEmailSent,
+			time.Now().Add(-outboxRetention),
+		).
+		Updates(map[string]any{"html": "", "text": "", "headers": nil}).
+		Error
+}
+
+// outboxBackoff is the wait after the given number of failed attempts.
+func outboxBackoff(attempts int) time.Duration {
+	backoff := outboxBaseBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= outboxMaxBackoff {
+			return outboxMaxBackoff
+		}
+	}
+	return backoff
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharon-xa/high-api/internal/database"
+	"github.com/sharon-xa/high-api/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// outboundEmailResponse leaves bodies out, they hold one-time passwords and
+// reset links.
+type outboundEmailResponse struct {
+	ID            uint       `json:"id"`
+	To            string     `json:"to"`
+	Subject       string     `json:"subject"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func newOutboundEmailResponse(e database.OutboundEmail) outboundEmailResponse {
+	response := outboundEmailResponse{
+		ID:        e.ID,
+		To:        e.To,
+		Subject:   e.Subject,
+		Status:    e.Status,
+		Attempts:  e.Attempts,
+		LastError: e.LastError,
+		SentAt:    e.SentAt,
+		CreatedAt: e.CreatedAt,
+	}
+	if e.Status == database.OutboundEmailPending {
+		response.NextAttemptAt = &e.NextAttemptAt
+	}
+	return response
+}
+
+// outboundEmailSnapshot is what the audit log keeps of an email.
+func outboundEmailSnapshot(e database.OutboundEmail) map[string]any {
+	return map[string]any{
+		"to":         e.To,
+		"subject":    e.Subject,
+		"status":     e.Status,
+		"attempts":   e.Attempts,
+		"last_error": e.LastError,
+	}
+}
+
+// outboundEmailFilters are the query parameters of the email list.
+type outboundEmailFilters struct {
+	Status string
+	// Failed narrows the list to emails whose last attempt failed.
+	Failed bool
+	To     string
+}
+
+func getOutboundEmailFilters(c *gin.Context) (f outboundEmailFilters, ok bool) {
+	switch f.Status = c.Query("status"); f.Status {
+	case "",
+		database.OutboundEmailPending,
+		database.OutboundEmailSent,
+		database.OutboundEmailDead:
+	default:
+		utils.Fail(c, &utils.APIError{Code: http.StatusBadRequest, Message: "invalid status"}, nil)
+		return f, false
+	}
+
+	failed, ok := getOptionalQueryBool(c, "failed")
+	if !ok {
+		return f, false
+	}
+	f.Failed = failed != nil && *failed
+	f.To = c.Query("to")
+	return f, true
+}
+
+// apply narrows a query on the outbound_emails table.
+func (f outboundEmailFilters) apply(q *gorm.DB) *gorm.DB {
+	if f.Status != "" {
+		q = q.Where("outbound_emails.status = ?", f.Status)
+	}
+	if f.Failed {
+		q = q.Where("outbound_emails.last_error <> ''")
+	}
+	if f.To != "" {
+		q = q.Where("outbound_emails.to = ?", f.To)
+	}
+	return q
+}
+
+// getOutboundEmails lists the emails of the outbox, newest first.
+func (s *Server) getOutboundEmails(c *gin.Context) {
+	filters, ok := getOutboundEmailFilters(c)
+	if !ok {
+		return
+	}
+	after, ok := getCursor(c)
+	if !ok {
+		return
+	}
+	limit := getPageLimit(c)
+
+	q := filters.apply(s.db.Model(&database.OutboundEmail{}))
+
+	var total int64
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	page := q.Session(&gorm.Session{}).Omit("html", "text")
+	if after != nil {
+		page = page.Where(
+			"(outbound_emails.created_at, outbound_emails.id) < (?, ?)",
+			after.Time,
+			after.ID,
+		)
+	}
+
+	var emails []database.OutboundEmail
+	err := page.Order("outbound_emails.created_at DESC, outbound_emails.id DESC").
+		Limit(limit + 1).
+		Find(&emails).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	nextCursor := ""
+	if len(emails) > limit {
+		emails = emails[:limit]
+		last := emails[limit-1]
+		nextCursor = encodeCursor(cursor{Time: last.CreatedAt, ID: last.ID})
+	}
+
+	response := make([]outboundEmailResponse, len(emails))
+	for i, e := range emails {
+		response[i] = newOutboundEmailResponse(e)
+	}
+
+	utils.SuccessPageWithTotal(c, "", response, nextCursor, total)
+}
+
+var errEmailNotDead = &utils.APIError{
+	Code:    http.StatusConflict,
+	Message: "only dead emails can be requeued",
+}
+
+// requeueOutboundEmail gives a dead email a fresh set of attempts.
+func (s *Server) requeueOutboundEmail(c *gin.Context) {
+	emailID := convParamToInt(c, "id")
+	if emailID == 0 {
+		return
+	}
+
+	var email database.OutboundEmail
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Omit("html", "text").
+			Take(&email, emailID).
+			Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				utils.Fail(
+					c,
+					&utils.APIError{Code: http.StatusNotFound, Message: "email not found"},
+					err,
+				)
+				return err
+			}
+			utils.Fail(c, utils.ErrInternal, err)
+			return err
+		}
+		if email.Status != database.OutboundEmailDead {
+			utils.Fail(c, errEmailNotDead, nil)
+			return errEmailNotDead
+		}
+
+		before := outboundEmailSnapshot(email)
+		email.Status = database.OutboundEmailPending
+		email.Attempts = 0
+		email.NextAttemptAt = time.Now()
+		err = tx.Model(&email).Updates(map[string]any{
+			"status":          email.Status,
+			"attempts":        email.Attempts,
+			"next_attempt_at": email.NextAttemptAt,
+		}).Error
+		if err != nil {
+			utils.Fail(c, utils.ErrInternal, err)
+			return err
+		}
+
+		err = audit(c, tx, auditEntry{
+			Action:     database.AuditActionEmailRequeue,
+			TargetType: database.AuditTargetEmail,
+			TargetID:   email.ID,
+			Before:     before,
+			After:      outboundEmailSnapshot(email),
+		})
+		if err != nil {
+			utils.Fail(c, utils.ErrInternal, err)
+		}
+		return err
+	})
+	if err != nil {
+		return
+	}
+	s.outbox.kick()
+
+	utils.Success(c, "email requeued", newOutboundEmailResponse(email))
+}
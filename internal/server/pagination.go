@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharon-xa/high-api/internal/utils"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// cursor marks the last item of a page for keyset pagination: the value of
+// the timestamp or score the list is sorted by, and the ID as a tie breaker.
+// It is handed to clients as an opaque base64 string.
+type cursor struct {
+	Time  time.Time `json:"t"`
+	Score int64     `json:"s,omitempty"`
+	ID    uint      `json:"id"`
+}
+
+func encodeCursor(cur cursor) string {
+	b, err := json.Marshal(cur)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// getCursor reads the "cursor" query parameter.
+// It returns a nil cursor when the parameter is absent, and ok=false
+// (after responding) when it is malformed.
+func getCursor(c *gin.Context) (cur *cursor, ok bool) {
+	raw := c.Query("cursor")
+	if raw == "" {
+		return nil, true
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err == nil {
+		cur = &cursor{}
+		err = json.Unmarshal(b, cur)
+	}
+	if err != nil || cur.ID == 0 {
+		utils.Fail(
+			c,
+			&utils.APIError{Code: http.StatusBadRequest, Message: "Invalid cursor"},
+			err,
+		)
+		return nil, false
+	}
+
+	return cur, true
+}
+
+// getPageLimit reads the "limit" query parameter, falling back to
+// defaultPageLimit and capping it at maxPageLimit.
+func getPageLimit(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		return defaultPageLimit
+	}
+
+	return min(limit, maxPageLimit)
+}
+
+// getPageOffset reads the 1-based "page" query parameter for endpoints that
+// can't use keyset pagination, such as relevance-ranked results.
+func getPageOffset(c *gin.Context, limit int) int {
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	return (page - 1) * limit
+}
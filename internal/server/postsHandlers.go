@@ -2,26 +2,36 @@ package server
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sharon-xa/high-api/internal/content"
 	"github.com/sharon-xa/high-api/internal/database"
+	"github.com/sharon-xa/high-api/internal/rbac"
 	"github.com/sharon-xa/high-api/internal/utils"
 	"gorm.io/gorm"
 )
 
 type PostResponse struct {
-	ID        uint          `json:"id"`
-	Title     string        `json:"title"`
-	Content   string        `json:"content"`
-	Image     string        `json:"image"`
-	CreatedAt time.Time     `json:"created_at"`
-	User      UserBrief     `json:"user"`
-	Category  CategoryBrief `json:"category"`
-	Tags      []string      `json:"tags"`
+	ID            uint          `json:"id"`
+	Title         string        `json:"title"`
+	Slug          string        `json:"slug"`
+	Content       string        `json:"content"`
+	ContentFormat string        `json:"content_format"`
+	ContentHTML   string        `json:"content_html"`
+	Image         string        `json:"image"`
+	Status        string        `json:"status"`
+	PublishAt     *time.Time    `json:"publish_at,omitempty"`
+	CreatedAt     time.Time     `json:"created_at"`
+	User          UserBrief     `json:"user"`
+	Category      CategoryBrief `json:"category"`
+	Tags          []string      `json:"tags"`
+	reactionSummary
 }
 
 type UserBrief struct {
@@ -35,6 +45,184 @@ type CategoryBrief struct {
 	Name string `json:"name"`
 }
 
+// newPostResponse expects the User, Category and Tags associations of p to
+// be loaded.
+func newPostResponse(p database.Post) PostResponse {
+	tags := make([]string, len(p.Tags))
+	for i, tag := range p.Tags {
+		tags[i] = tag.Name
+	}
+
+	// Posts written before content_html existed are rendered on the fly.
+	contentHTML := p.ContentHTML
+	if contentHTML == "" && p.Content != "" {
+		contentHTML, _ = content.Render(p.Content, p.ContentFormat)
+	}
+
+	return PostResponse{
+		ID:            p.ID,
+		Title:         p.Title,
+		Slug:          p.Slug,
+		Content:       p.Content,
+		ContentFormat: p.ContentFormat,
+		ContentHTML:   contentHTML,
+		Image:         p.Image,
+		Status:        p.Status,
+		PublishAt:     p.PublishAt,
+		CreatedAt:     p.CreatedAt,
+		Tags:          tags,
+		User: UserBrief{
+			ID:    p.UserID,
+			Name:  p.User.Name,
+			Image: p.User.Image,
+		},
+		Category: CategoryBrief{
+			ID:   p.Category.ID,
+			Name: p.Category.Name,
+		},
+	}
+}
+
+type postFilters struct {
+	CategoryID uint
+	AuthorID   uint
+	Tags       []string
+	From       time.Time
+	To         time.Time
+}
+
+// getPostFilters reads the post listing filters from the query string:
+// category, author, tag (repeatable or comma separated), from and to.
+func getPostFilters(c *gin.Context) (f postFilters, ok bool) {
+	if f.CategoryID, ok = getOptionalQueryUInt(c, "category"); !ok {
+		return f, false
+	}
+	if f.AuthorID, ok = getOptionalQueryUInt(c, "author"); !ok {
+		return f, false
+	}
+	if f.From, ok = getOptionalQueryTime(c, "from", false); !ok {
+		return f, false
+	}
+	if f.To, ok = getOptionalQueryTime(c, "to", true); !ok {
+		return f, false
+	}
+
+	for _, param := range c.QueryArray("tag") {
+		for tagName := range strings.SplitSeq(param, ",") {
+			tagName = strings.ToLower(strings.TrimSpace(tagName))
+			if tagName != "" {
+				f.Tags = append(f.Tags, tagName)
+			}
+		}
+	}
+
+	return f, true
+}
+
+// apply narrows a query on the posts table. A post matches the tag filter
+// when it has any of the requested tags.
+func (f postFilters) apply(q *gorm.DB) *gorm.DB {
+	if f.CategoryID != 0 {
+		q = q.Where("posts.category_id = ?", f.CategoryID)
+	}
+	if f.AuthorID != 0 {
+		q = q.Where("posts.user_id = ?", f.AuthorID)
+	}
+	if !f.From.IsZero() {
+		q = q.Where("posts.publish_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		q = q.Where("posts.publish_at < ?", f.To)
+	}
+	if len(f.Tags) > 0 {
+		q = q.Where(
+			`posts.id IN (
+				SELECT post_tags.post_id FROM post_tags
+				JOIN tags ON tags.id = post_tags.tag_id
+				WHERE tags.name IN ?
+			)`,
+			f.Tags,
+		)
+	}
+	return q
+}
+
+// publishedPosts is a scope limiting a posts query to what the public may see.
+func publishedPosts(q *gorm.DB) *gorm.DB {
+	return q.Where("posts.status = ?", database.PostStatusPublished)
+}
+
+// postListQuery is the base query for every public list of posts.
+func (s *Server) postListQuery(f postFilters) *gorm.DB {
+	return f.apply(s.db.Model(&database.Post{}).Scopes(publishedPosts)).
+		Preload("User").
+		Preload("Category").
+		Preload("Tags")
+}
+
+// findPostsPage loads one keyset page of published posts from q, newest
+// first, returning the cursor of the next page (empty on the last one).
+func findPostsPage(q *gorm.DB, after *cursor, limit int) ([]database.Post, string, error) {
+	if after != nil {
+		q = q.Where("(posts.publish_at, posts.id) < (?, ?)", after.Time, after.ID)
+	}
+
+	var posts []database.Post
+	err := q.Order("posts.publish_at DESC, posts.id DESC").
+		Limit(limit + 1).
+		Find(&posts).
+		Error
+	if err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(posts) > limit {
+		posts = posts[:limit]
+		last := posts[limit-1]
+		nextCursor = encodeCursor(cursor{Time: publishTime(last), ID: last.ID})
+	}
+
+	return posts, nextCursor, nil
+}
+
+// publishTime is when p went public, falling back to its creation time for
+// rows that predate the publish_at column.
+func publishTime(p database.Post) time.Time {
+	if p.PublishAt != nil {
+		return *p.PublishAt
+	}
+	return p.CreatedAt
+}
+
+func (s *Server) getPosts(c *gin.Context) {
+	filters, ok := getPostFilters(c)
+	if !ok {
+		return
+	}
+	after, ok := getCursor(c)
+	if !ok {
+		return
+	}
+
+	posts, nextCursor, err := findPostsPage(s.postListQuery(filters), after, getPageLimit(c))
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	response := make([]PostResponse, len(posts))
+	for i, p := range posts {
+		response[i] = newPostResponse(p)
+	}
+	if err := s.attachPostReactions(c, response); err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	utils.SuccessPage(c, "", response, nextCursor)
+}
+
 func (s *Server) getPost(c *gin.Context) {
 	postID := convParamToInt(c, "id")
 	if postID == 0 {
@@ -42,7 +230,13 @@ func (s *Server) getPost(c *gin.Context) {
 	}
 
 	var p database.Post
-	if err := s.db.Preload("User").Preload("Category").Preload("Tags").First(&p, postID).Error; err != nil {
+	err := s.db.Scopes(publishedPosts).
+		Preload("User").
+		Preload("Category").
+		Preload("Tags").
+		First(&p, postID).
+		Error
+	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			utils.Fail(c, utils.ErrNotFound, err)
 			return
@@ -52,35 +246,26 @@ func (s *Server) getPost(c *gin.Context) {
 		return
 	}
 
-	tags := make([]string, len(p.Tags))
-	for i, tag := range p.Tags {
-		tags[i] = tag.Name
+	response := []PostResponse{newPostResponse(p)}
+	if err := s.attachPostReactions(c, response); err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
 	}
 
-	utils.Success(c, "", PostResponse{
-		ID:        p.ID,
-		Title:     p.Title,
-		Content:   p.Content,
-		Image:     p.Image,
-		CreatedAt: p.CreatedAt,
-		Tags:      tags,
-		User: UserBrief{
-			ID:    p.UserID,
-			Name:  p.User.Name,
-			Image: p.User.Image,
-		},
-		Category: CategoryBrief{
-			ID:   p.Category.ID,
-			Name: p.Category.Name,
-		},
-	})
+	utils.Success(c, "", response[0])
 }
 
 type commentResponse struct {
-	ID        uint              `json:"id"`
-	Content   string            `json:"content"`
-	CreatedAt time.Time         `json:"created_at"`
-	User      publicUserSummary `json:"user"`
+	ID         uint              `json:"id"`
+	ParentID   *uint             `json:"parent_id"`
+	Depth      int               `json:"depth"`
+	Content    string            `json:"content"`
+	Status     string            `json:"status"`
+	Deleted    bool              `json:"deleted"`
+	ReplyCount int64             `json:"reply_count"`
+	CreatedAt  time.Time         `json:"created_at"`
+	User       publicUserSummary `json:"user"`
+	reactionSummary
 }
 
 type publicUserSummary struct {
@@ -98,6 +283,7 @@ func (s *Server) getCommentsOfPost(c *gin.Context) {
 
 	var exists bool
 	err := s.db.Model(&database.Post{}).
+		Scopes(publishedPosts).
 		Select("1").
 		Where("id = ?", postID).
 		Limit(1).
@@ -116,38 +302,14 @@ func (s *Server) getCommentsOfPost(c *gin.Context) {
 		return
 	}
 
-	var comments []database.Comment
-	err = s.db.Preload("User").
-		Where("post_id = ?", postID).
-		Order("created_at ASC").
-		Find(&comments).
-		Error
-	if err != nil {
-		utils.Fail(c, utils.ErrInternal, err)
-		return
-	}
-
-	var response []commentResponse
-	for _, cm := range comments {
-		response = append(response, commentResponse{
-			ID:        cm.ID,
-			Content:   cm.Content,
-			CreatedAt: cm.CreatedAt,
-			User: publicUserSummary{
-				ID:    cm.User.ID,
-				Name:  cm.User.Name,
-				Image: cm.User.Image,
-			},
-		})
-	}
-	if len(response) == 0 {
-		c.Status(http.StatusNoContent)
-		return
-	}
-
-	// TODO: we might want to limit the number of comments returned to the user
-	// paginate the comments
-	utils.Success(c, "", response)
+	// Only the top level comments are listed, replies are fetched per
+	// thread from /comments/:id/replies.
+	s.listComments(
+		c,
+		s.db.Model(&database.Comment{}).
+			Scopes(visibleComments(getOptionalUserID(c))).
+			Where("comments.post_id = ? AND comments.parent_id IS NULL", postID),
+	)
 }
 
 func (s *Server) addPost(c *gin.Context) {
@@ -170,8 +332,8 @@ func (s *Server) addPost(c *gin.Context) {
 	if title == "" {
 		return
 	}
-	content := getRequiredFormField(c, "content")
-	if content == "" {
+	postContent := getRequiredFormField(c, "content")
+	if postContent == "" {
 		return
 	}
 	tagsStr := getRequiredFormField(c, "tags")
@@ -179,31 +341,41 @@ func (s *Server) addPost(c *gin.Context) {
 		return
 	}
 
-	// Split tags and trim whitespace
-	rawTags := strings.Split(tagsStr, ",")
-	var tags []database.Tag
-	for _, tagName := range rawTags {
-		tagName = strings.TrimSpace(tagName)
-		tagName = strings.ToLower(tagName)
-		if tagName == "" {
-			continue
-		}
-		var tag database.Tag
-		err = s.db.Where("name = ?", tagName).
-			FirstOrCreate(&tag, database.Tag{Name: tagName}).
-			Error
+	var publishAt *time.Time
+	if publishAtStr := c.PostForm("publishAt"); publishAtStr != "" {
+		t, err := time.Parse(time.RFC3339, publishAtStr)
 		if err != nil {
-			utils.Fail(
-				c,
-				&utils.APIError{
-					Code:    http.StatusInternalServerError,
-					Message: "Failed to create or fetch tag",
-				},
-				err,
-			)
+			utils.Fail(c, &utils.APIError{
+				Code:    http.StatusBadRequest,
+				Message: "publishAt must be in RFC 3339 format",
+			}, err)
 			return
 		}
-		tags = append(tags, tag)
+		publishAt = &t
+	}
+
+	var p database.Post
+	p.Content = postContent
+	if apiErr := setPostContentFormat(&p, c.PostForm("contentFormat")); apiErr != nil {
+		utils.Fail(c, apiErr, nil)
+		return
+	}
+	if apiErr := applyPostStatus(&p, c.PostForm("status"), publishAt); apiErr != nil {
+		utils.Fail(c, apiErr, nil)
+		return
+	}
+
+	tags, err := findOrCreateTags(s.db, tagsStr)
+	if err != nil {
+		utils.Fail(
+			c,
+			&utils.APIError{
+				Code:    http.StatusInternalServerError,
+				Message: "Failed to create or fetch tag",
+			},
+			err,
+		)
+		return
 	}
 
 	image, imageHeader, err := c.Request.FormFile("image")
@@ -223,15 +395,21 @@ func (s *Server) addPost(c *gin.Context) {
 	}
 
 	defer image.Close()
-	p := database.Post{
-		UserID:     uint(userID),
-		CategoryID: categoryID,
-		Title:      title,
-		Content:    content,
-		Tags:       tags,
-		Image:      url,
-	}
-	if err := s.db.Create(&p).Error; err != nil {
+	p.UserID = uint(userID)
+	p.CategoryID = categoryID
+	p.Title = title
+	p.Tags = tags
+	p.Image = url
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if p.Slug, err = uniquePostSlug(tx, p.Title, 0); err != nil {
+			return err
+		}
+		if err := tx.Create(&p).Error; err != nil {
+			return err
+		}
+		return recordRevision(tx, &p, p.UserID)
+	})
+	if err != nil {
 		utils.Fail(c, &utils.APIError{
 			Code:    http.StatusInternalServerError,
 			Message: "Failed to create post",
@@ -244,28 +422,7 @@ func (s *Server) addPost(c *gin.Context) {
 		return
 	}
 
-	CreatedTags := make([]string, len(p.Tags))
-	for i, tag := range p.Tags {
-		CreatedTags[i] = tag.Name
-	}
-
-	utils.Created(c, "post created successfully", PostResponse{
-		ID:        p.ID,
-		Title:     p.Title,
-		Content:   p.Content,
-		Image:     p.Image,
-		CreatedAt: p.CreatedAt,
-		Tags:      CreatedTags,
-		User: UserBrief{
-			ID:    p.UserID,
-			Name:  p.User.Name,
-			Image: p.User.Image,
-		},
-		Category: CategoryBrief{
-			ID:   p.Category.ID,
-			Name: p.Category.Name,
-		},
-	})
+	utils.Created(c, "post created successfully", newPostResponse(p))
 }
 
 type updatePostRequest struct {
@@ -273,6 +430,13 @@ type updatePostRequest struct {
 	Content    string `json:"content"    binding:"required"`
 	CategoryID uint   `json:"categoryId" binding:"required"`
 	Tags       string `json:"tags"       binding:"required"`
+	// ContentFormat is optional, the post keeps its current format when it
+	// is omitted.
+	ContentFormat string `json:"contentFormat"`
+	// Status and PublishAt are optional, the post keeps its current status
+	// when both are omitted.
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publishAt"`
 }
 
 func (s *Server) updatePost(c *gin.Context) {
@@ -320,28 +484,53 @@ func (s *Server) updatePost(c *gin.Context) {
 		return
 	}
 
-	post.Title = req.Title
-	post.Content = req.Content
-	post.CategoryID = req.CategoryID
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := ensureBaselineRevision(tx, &post); err != nil {
+			utils.Fail(c, utils.ErrInternal, err)
+			return err
+		}
+
+		titleChanged := post.Title != req.Title || post.Slug == ""
+		post.Title = req.Title
+		post.Content = req.Content
+		post.CategoryID = req.CategoryID
 
-	rawTags := strings.Split(req.Tags, ",")
-	var tags []database.Tag
-	for _, tagName := range rawTags {
-		tagName = strings.ToLower(strings.TrimSpace(tagName))
-		if tagName == "" {
-			continue
+		if apiErr := setPostContentFormat(&post, req.ContentFormat); apiErr != nil {
+			utils.Fail(c, apiErr, nil)
+			return apiErr
 		}
-		var tag database.Tag
-		if err := s.db.Where("name = ?", tagName).FirstOrCreate(&tag, database.Tag{Name: tagName}).Error; err != nil {
+
+		if titleChanged {
+			if err := updatePostSlug(tx, &post); err != nil {
+				utils.Fail(c, utils.ErrInternal, err)
+				return err
+			}
+		}
+
+		if req.Status != "" || req.PublishAt != nil {
+			if apiErr := applyPostStatus(&post, req.Status, req.PublishAt); apiErr != nil {
+				utils.Fail(c, apiErr, nil)
+				return apiErr
+			}
+		}
+
+		if err := replacePostTags(tx, &post, req.Tags); err != nil {
 			utils.Fail(c, utils.ErrInternal, err)
-			return
+			return err
 		}
-		tags = append(tags, tag)
-	}
-	s.db.Model(&post).Association("Tags").Replace(&tags)
 
-	if err := s.db.Save(&post).Error; err != nil {
-		utils.Fail(c, utils.ErrInternal, err)
+		if err := tx.Save(&post).Error; err != nil {
+			utils.Fail(c, utils.ErrInternal, err)
+			return err
+		}
+
+		if err := recordRevision(tx, &post, uint(userID)); err != nil {
+			utils.Fail(c, utils.ErrInternal, err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
 		return
 	}
 
@@ -380,7 +569,7 @@ func (s *Server) deletePost(c *gin.Context) {
 		utils.Fail(c, utils.ErrInternal, err)
 		return
 	}
-	if p.UserID != uint(userID) && claims.Role != "admin" {
+	if p.UserID != uint(userID) && !rbac.Can(claims.Role, rbac.PostsDeleteAny) {
 		utils.Fail(
 			c,
 			&utils.APIError{
@@ -392,13 +581,29 @@ func (s *Server) deletePost(c *gin.Context) {
 		return
 	}
 
-	results := s.db.Delete(&database.Post{}, p.ID)
-	if results.Error != nil {
-		utils.Fail(c, utils.ErrInternal, results.Error)
+	var removed bool
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if removed, err = removePost(tx, p.ID); err != nil || !removed {
+			return err
+		}
+		// Only removals of other people's posts go to the audit log.
+		if p.UserID == uint(userID) {
+			return nil
+		}
+		return audit(c, tx, auditEntry{
+			Action:     database.AuditActionPostDelete,
+			TargetType: database.AuditTargetPost,
+			TargetID:   p.ID,
+			Before:     postSnapshot(&p),
+		})
+	})
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
 		return
 	}
 
-	if results.RowsAffected == 0 {
+	if !removed {
 		utils.Fail(
 			c,
 			&utils.APIError{Code: http.StatusNotFound, Message: "post not found"},
@@ -407,5 +612,248 @@ func (s *Server) deletePost(c *gin.Context) {
 		return
 	}
 
+	if p.UserID != uint(userID) {
+		s.notifyModeration(
+			p.UserID,
+			database.NotificationTargetPost,
+			p.ID,
+			nil,
+			fmt.Sprintf("Your post %q was removed by a moderator.", p.Title),
+		)
+	}
 	utils.Success(c, "post deleted successfully", nil)
 }
+
+// postSnapshot describes a post for the audit log.
+func postSnapshot(p *database.Post) gin.H {
+	return gin.H{
+		"user_id":     p.UserID,
+		"category_id": p.CategoryID,
+		"title":       p.Title,
+		"slug":        p.Slug,
+		"status":      p.Status,
+	}
+}
+
+// removePost deletes a post, reporting whether it still existed.
+func removePost(tx *gorm.DB, postID uint) (bool, error) {
+	results := tx.Delete(&database.Post{}, postID)
+	return results.RowsAffected > 0, results.Error
+}
+
+// findOrCreateTags turns a comma separated list of tag names into tags,
+// creating the ones that don't exist yet.
+func findOrCreateTags(tx *gorm.DB, tagsStr string) ([]database.Tag, error) {
+	var tags []database.Tag
+	for tagName := range strings.SplitSeq(tagsStr, ",") {
+		tagName = strings.ToLower(strings.TrimSpace(tagName))
+		if tagName == "" {
+			continue
+		}
+		var tag database.Tag
+		err := tx.Where("name = ?", tagName).
+			FirstOrCreate(&tag, database.Tag{Name: tagName}).
+			Error
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// replacePostTags sets the tags of p to the comma separated tagsStr.
+func replacePostTags(tx *gorm.DB, p *database.Post, tagsStr string) error {
+	tags, err := findOrCreateTags(tx, tagsStr)
+	if err != nil {
+		return err
+	}
+	if err := tx.Model(p).Association("Tags").Replace(&tags); err != nil {
+		return err
+	}
+	p.Tags = tags
+	return nil
+}
+
+// setPostContentFormat switches p to format, keeping its current format (or
+// the default one for new posts) when format is empty, and renders
+// p.Content into p.ContentHTML.
+func setPostContentFormat(p *database.Post, format string) *utils.APIError {
+	if format != "" {
+		if !content.ValidFormat(format) {
+			return &utils.APIError{Code: http.StatusBadRequest, Message: "invalid content format"}
+		}
+		p.ContentFormat = format
+	}
+	if p.ContentFormat == "" {
+		p.ContentFormat = content.FormatMarkdown
+	}
+
+	contentHTML, err := content.Render(p.Content, p.ContentFormat)
+	if err != nil {
+		return &utils.APIError{Code: http.StatusBadRequest, Message: "content couldn't be rendered"}
+	}
+	p.ContentHTML = contentHTML
+	return nil
+}
+
+// applyPostStatus moves p to the requested status. An empty status publishes
+// the post, unless publishAt lies in the future, in which case it is
+// scheduled. Posts keep their original publish time when re-published.
+func applyPostStatus(p *database.Post, status string, publishAt *time.Time) *utils.APIError {
+	now := time.Now()
+
+	switch status {
+	case "", database.PostStatusPublished, database.PostStatusScheduled:
+		if publishAt != nil && publishAt.After(now) {
+			p.Status = database.PostStatusScheduled
+			p.PublishAt = publishAt
+			return nil
+		}
+		if status == database.PostStatusScheduled {
+			return &utils.APIError{
+				Code:    http.StatusBadRequest,
+				Message: "publishAt must be in the future to schedule a post",
+			}
+		}
+		if p.PublishAt == nil || p.PublishAt.After(now) {
+			p.PublishAt = &now
+		}
+		p.Status = database.PostStatusPublished
+	case database.PostStatusDraft:
+		p.Status = database.PostStatusDraft
+		p.PublishAt = nil
+	case database.PostStatusArchived:
+		p.Status = database.PostStatusArchived
+	default:
+		return &utils.APIError{Code: http.StatusBadRequest, Message: "invalid status"}
+	}
+
+	return nil
+}
+
+type publishPostReq struct {
+	PublishAt *time.Time `json:"publishAt"`
+}
+
+func (s *Server) publishPost(c *gin.Context) {
+	var req publishPostReq
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+
+	post, ok := s.getAuthoredPost(c)
+	if !ok {
+		return
+	}
+
+	if apiErr := applyPostStatus(post, "", req.PublishAt); apiErr != nil {
+		utils.Fail(c, apiErr, nil)
+		return
+	}
+
+	err := s.db.Model(post).
+		Select("status", "publish_at").
+		Updates(post).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	if post.Status == database.PostStatusScheduled {
+		utils.Success(c, "post scheduled successfully", nil)
+		return
+	}
+	utils.Success(c, "post published successfully", nil)
+}
+
+var draftStatuses = []string{database.PostStatusDraft, database.PostStatusScheduled}
+
+// getMyDrafts lists the caller's unpublished posts, most recently edited
+// first. The status query parameter narrows the list to draft, scheduled
+// or archived posts.
+func (s *Server) getMyDrafts(c *gin.Context) {
+	claims := getAccessClaims(c)
+	if claims == nil {
+		return
+	}
+
+	statuses := draftStatuses
+	switch status := c.Query("status"); status {
+	case "":
+	case database.PostStatusDraft, database.PostStatusScheduled, database.PostStatusArchived:
+		statuses = []string{status}
+	default:
+		utils.Fail(c, &utils.APIError{Code: http.StatusBadRequest, Message: "invalid status"}, nil)
+		return
+	}
+
+	limit := getPageLimit(c)
+
+	var posts []database.Post
+	err := s.db.Preload("User").
+		Preload("Category").
+		Preload("Tags").
+		Where("user_id = ? AND status IN ?", claims.Subject, statuses).
+		Order("updated_at DESC, id DESC").
+		Offset(getPageOffset(c, limit)).
+		Limit(limit).
+		Find(&posts).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	response := make([]PostResponse, len(posts))
+	for i, p := range posts {
+		response[i] = newPostResponse(p)
+	}
+
+	utils.Success(c, "", response)
+}
+
+// getAuthoredPost loads the post named by the "id" path parameter and makes
+// sure the caller wrote it, responding with the matching error otherwise.
+func (s *Server) getAuthoredPost(c *gin.Context) (*database.Post, bool) {
+	claims := getAccessClaims(c)
+	if claims == nil {
+		return nil, false
+	}
+
+	postID := convParamToInt(c, "id")
+	if postID == 0 {
+		return nil, false
+	}
+
+	var post database.Post
+	if err := s.db.First(&post, postID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.Fail(c, utils.ErrNotFound, err)
+			return nil, false
+		}
+		utils.Fail(c, utils.ErrInternal, err)
+		return nil, false
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return nil, false
+	}
+	if post.UserID != uint(userID) {
+		utils.Fail(
+			c,
+			&utils.APIError{
+				Code:    http.StatusForbidden,
+				Message: "you're not allowed to change this post",
+			},
+			nil,
+		)
+		return nil, false
+	}
+
+	return &post, true
+}
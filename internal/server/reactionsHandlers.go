@@ -0,0 +1,325 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharon-xa/high-api/internal/database"
+	"github.com/sharon-xa/high-api/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// reactionSummary is embedded in the responses of everything users can
+// react to.
+type reactionSummary struct {
+	Reactions  map[string]int64 `json:"reactions"`
+	MyReaction string           `json:"my_reaction,omitempty"`
+}
+
+// loadReactions returns the reaction summary of every target, including the
+// reaction left by userID unless it is 0.
+func (s *Server) loadReactions(
+	targetType string,
+	targetIDs []uint,
+	userID uint,
+) (map[uint]reactionSummary, error) {
+	summaries := make(map[uint]reactionSummary, len(targetIDs))
+	for _, id := range targetIDs {
+		summaries[id] = reactionSummary{Reactions: map[string]int64{}}
+	}
+	if len(targetIDs) == 0 {
+		return summaries, nil
+	}
+
+	var counts []database.ReactionCount
+	err := s.db.Where("target_type = ? AND target_id IN ? AND count > 0", targetType, targetIDs).
+		Find(&counts).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	for _, count := range counts {
+		summaries[count.TargetID].Reactions[count.Type] = count.Count
+	}
+
+	if userID == 0 {
+		return summaries, nil
+	}
+
+	var mine []database.Reaction
+	err = s.db.Select("target_id", "type").
+		Where("user_id = ? AND target_type = ? AND target_id IN ?", userID, targetType, targetIDs).
+		Find(&mine).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	for _, reaction := range mine {
+		summary := summaries[reaction.TargetID]
+		summary.MyReaction = reaction.Type
+		summaries[reaction.TargetID] = summary
+	}
+
+	return summaries, nil
+}
+
+// attachPostReactions fills in the reactions of posts for the caller.
+func (s *Server) attachPostReactions(c *gin.Context, posts []PostResponse) error {
+	ids := make([]uint, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+
+	summaries, err := s.loadReactions(database.ReactionTargetPost, ids, getOptionalUserID(c))
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].reactionSummary = summaries[posts[i].ID]
+	}
+	return nil
+}
+
+// attachCommentReactions fills in the reactions of comments for the caller.
+// Deleted comments keep their reactions in case they are restored, but show
+// none.
+func (s *Server) attachCommentReactions(c *gin.Context, comments []commentResponse) error {
+	ids := make([]uint, 0, len(comments))
+	for _, cm := range comments {
+		if !cm.Deleted {
+			ids = append(ids, cm.ID)
+		}
+	}
+
+	summaries, err := s.loadReactions(database.ReactionTargetComment, ids, getOptionalUserID(c))
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		summary, ok := summaries[comments[i].ID]
+		if !ok {
+			summary = reactionSummary{Reactions: map[string]int64{}}
+		}
+		comments[i].reactionSummary = summary
+	}
+	return nil
+}
+
+// adjustReactionCount adds delta to the count of one reaction type on a
+// target, creating the counter on first use.
+func adjustReactionCount(
+	tx *gorm.DB,
+	targetType string,
+	targetID uint,
+	reactionType string,
+	delta int64,
+) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "target_type"}, {Name: "target_id"}, {Name: "type"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"count": gorm.Expr("GREATEST(reaction_counts.count + ?, 0)", delta),
+		}),
+	}).Create(&database.ReactionCount{
+		TargetType: targetType,
+		TargetID:   targetID,
+		Type:       reactionType,
+		Count:      max(delta, 0),
+	}).Error
+}
+
+// releaseUserReactions takes the reactions of a user out of the counts,
+// ahead of the user being deleted.
+func releaseUserReactions(tx *gorm.DB, userID any) error {
+	return tx.Exec(`
+		UPDATE reaction_counts AS rc
+		SET count = GREATEST(rc.count - r.n, 0)
+		FROM (
+			SELECT target_type, target_id, type, COUNT(*) AS n
+			FROM reactions
+			WHERE user_id = ?
+			GROUP BY target_type, target_id, type
+		) AS r
+		WHERE rc.target_type = r.target_type AND rc.target_id = r.target_id AND rc.type = r.type`,
+		userID,
+	).Error
+}
+
+// deleteReactions drops the reactions of targets and their counts, once the
+// targets are gone for good. targetIDs is a list of IDs or a query selecting
+// them. Reactions have no foreign key on their target, nothing else cleans
+// them up.
+func deleteReactions(tx *gorm.DB, targetType string, targetIDs any) error {
+	target := tx.Where("target_type = ? AND target_id IN (?)", targetType, targetIDs)
+	if err := target.Session(&gorm.Session{}).Delete(&database.Reaction{}).Error; err != nil {
+		return err
+	}
+	return target.Session(&gorm.Session{}).Delete(&database.ReactionCount{}).Error
+}
+
+// deleteUserContentReactions drops the reactions on the posts and comments
+// that go away with a user: theirs, the comments on their posts, and the
+// replies below all of those.
+func deleteUserContentReactions(tx *gorm.DB, userID any) error {
+	posts := tx.Table("posts").Select("id").Where("user_id = ?", userID)
+	if err := deleteReactions(tx, database.ReactionTargetPost, posts); err != nil {
+		return err
+	}
+
+	comments := tx.Raw(`
+		WITH RECURSIVE doomed AS (
+			SELECT id FROM comments
+			WHERE user_id = @user OR post_id IN (SELECT id FROM posts WHERE user_id = @user)
+			UNION
+			SELECT comments.id FROM comments JOIN doomed ON comments.parent_id = doomed.id
+		)
+		SELECT id FROM doomed`,
+		sql.Named("user", userID),
+	)
+	return deleteReactions(tx, database.ReactionTargetComment, comments)
+}
+
+type reactionReq struct {
+	Type string `json:"type" binding:"required"`
+}
+
+func (s *Server) togglePostReaction(c *gin.Context) {
+	postID := convParamToInt(c, "id")
+	if postID == 0 {
+		return
+	}
+
+	var exists bool
+	err := s.db.Model(&database.Post{}).
+		Scopes(publishedPosts).
+		Select("1").
+		Where("id = ?", postID).
+		Limit(1).
+		Find(&exists).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+	if !exists {
+		utils.Fail(c, &utils.APIError{Code: http.StatusNotFound, Message: "post not found"}, nil)
+		return
+	}
+
+	s.toggleReaction(c, database.ReactionTargetPost, postID)
+}
+
+func (s *Server) toggleCommentReaction(c *gin.Context) {
+	commentID := convParamToInt(c, "id")
+	if commentID == 0 {
+		return
+	}
+
+	// Comments can't be reacted to while their post isn't published.
+	var exists bool
+	err := s.db.Model(&database.Comment{}).
+		Joins("JOIN posts ON posts.id = comments.post_id AND posts.deleted_at IS NULL").
+		Scopes(visibleComments(getOptionalUserID(c)), publishedPosts).
+		Select("1").
+		Where("comments.id = ? AND comments.tombstoned = ?", commentID, false).
+		Limit(1).
+		Find(&exists).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+	if !exists {
+		utils.Fail(c, &utils.APIError{Code: http.StatusNotFound, Message: "comment not found"}, nil)
+		return
+	}
+
+	s.toggleReaction(c, database.ReactionTargetComment, commentID)
+}
+
+// toggleReaction adds the requested reaction, switches the caller's existing
+// reaction to it, or removes it when the caller already left that reaction.
+// It responds with the target's updated reaction summary.
+func (s *Server) toggleReaction(c *gin.Context, targetType string, targetID uint) {
+	claims := getAccessClaims(c)
+	if claims == nil {
+		return
+	}
+
+	var req reactionReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+	if !slices.Contains(database.ReactionTypes, req.Type) {
+		utils.Fail(
+			c,
+			&utils.APIError{Code: http.StatusBadRequest, Message: "invalid reaction type"},
+			nil,
+		)
+		return
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var existing database.Reaction
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND target_type = ? AND target_id = ?", userID, targetType, targetID).
+			First(&existing).
+			Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = tx.Create(&database.Reaction{
+				UserID:     uint(userID),
+				TargetType: targetType,
+				TargetID:   targetID,
+				Type:       req.Type,
+			}).Error
+			if err != nil {
+				return err
+			}
+			return adjustReactionCount(tx, targetType, targetID, req.Type, 1)
+		}
+
+		if err := adjustReactionCount(tx, targetType, targetID, existing.Type, -1); err != nil {
+			return err
+		}
+
+		if existing.Type == req.Type {
+			return tx.Delete(&existing).Error
+		}
+
+		existing.Type = req.Type
+		if err := tx.Save(&existing).Error; err != nil {
+			return err
+		}
+		return adjustReactionCount(tx, targetType, targetID, req.Type, 1)
+	})
+	if err != nil {
+		if !utils.ValidateUniqueness(c, err, "reaction") {
+			return
+		}
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	summaries, err := s.loadReactions(targetType, []uint{targetID}, uint(userID))
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	utils.Success(c, "", summaries[targetID])
+}
@@ -0,0 +1,657 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharon-xa/high-api/internal/database"
+	"github.com/sharon-xa/high-api/internal/rbac"
+	"github.com/sharon-xa/high-api/internal/utils"
+	"gorm.io/gorm"
+)
+
+const maxReportDetailsLen = 2000
+
+var errReportTargetGone = &utils.APIError{
+	Code:    http.StatusConflict,
+	Message: "the reported content no longer exists",
+}
+
+type reportResponse struct {
+	ID         uint       `json:"id"`
+	TargetType string     `json:"target_type"`
+	TargetID   uint       `json:"target_id"`
+	Reason     string     `json:"reason"`
+	Details    string     `json:"details"`
+	Status     string     `json:"status"`
+	Reporter   UserBrief  `json:"reporter"`
+	Assignee   *UserBrief `json:"assignee"`
+	Action     string     `json:"action,omitempty"`
+	Note       string     `json:"note,omitempty"`
+	Resolver   *UserBrief `json:"resolver,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func newReportResponse(r database.Report) reportResponse {
+	resp := reportResponse{
+		ID:         r.ID,
+		TargetType: r.TargetType,
+		TargetID:   r.TargetID,
+		Reason:     r.Reason,
+		Details:    r.Details,
+		Status:     r.Status,
+		Reporter:   UserBrief{ID: r.Reporter.ID, Name: r.Reporter.Name, Image: r.Reporter.Image},
+		Action:     r.Action,
+		Note:       r.Note,
+		ResolvedAt: r.ResolvedAt,
+		CreatedAt:  r.CreatedAt,
+	}
+	if r.Assignee != nil {
+		resp.Assignee = &UserBrief{ID: r.Assignee.ID, Name: r.Assignee.Name, Image: r.Assignee.Image}
+	}
+	if r.Resolver != nil {
+		resp.Resolver = &UserBrief{ID: r.Resolver.ID, Name: r.Resolver.Name, Image: r.Resolver.Image}
+	}
+	return resp
+}
+
+type reportReq struct {
+	TargetType string `json:"targetType" binding:"required"`
+	TargetID   uint   `json:"targetId"   binding:"required"`
+	Reason     string `json:"reason"     binding:"required"`
+	Details    string `json:"details"`
+}
+
+func (s *Server) addReport(c *gin.Context) {
+	claims := getAccessClaims(c)
+	if claims == nil {
+		return
+	}
+
+	var req reportReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+	if !slices.Contains(database.ReportReasons, req.Reason) {
+		utils.Fail(c, &utils.APIError{Code: http.StatusBadRequest, Message: "invalid reason"}, nil)
+		return
+	}
+	if len(req.Details) > maxReportDetailsLen {
+		utils.Fail(
+			c,
+			&utils.APIError{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("details can't be longer than %d characters", maxReportDetailsLen),
+			},
+			nil,
+		)
+		return
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	var target *gorm.DB
+	switch req.TargetType {
+	case database.ReportTargetPost:
+		target = s.db.Model(&database.Post{}).Scopes(publishedPosts)
+	case database.ReportTargetComment:
+		target = s.db.Model(&database.Comment{}).Where("tombstoned = ?", false)
+	case database.ReportTargetUser:
+		if req.TargetID == uint(userID) {
+			utils.Fail(
+				c,
+				&utils.APIError{Code: http.StatusBadRequest, Message: "you can't report yourself"},
+				nil,
+			)
+			return
+		}
+		target = s.db.Model(&database.User{})
+	default:
+		utils.Fail(
+			c,
+			&utils.APIError{Code: http.StatusBadRequest, Message: "invalid target type"},
+			nil,
+		)
+		return
+	}
+
+	var exists bool
+	err = target.Select("1").Where("id = ?", req.TargetID).Limit(1).Find(&exists).Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+	if !exists {
+		utils.Fail(
+			c,
+			&utils.APIError{
+				Code:    http.StatusNotFound,
+				Message: req.TargetType + " not found",
+			},
+			nil,
+		)
+		return
+	}
+
+	report := database.Report{
+		ReporterID: uint(userID),
+		TargetType: req.TargetType,
+		TargetID:   req.TargetID,
+		Reason:     req.Reason,
+		Details:    req.Details,
+		Status:     database.ReportStatusOpen,
+	}
+	if err := s.db.Create(&report).Error; err != nil {
+		if !utils.ValidateUniqueness(c, err, "report") {
+			return
+		}
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	utils.Created(c, "thanks, a moderator will look into it", gin.H{"id": report.ID})
+}
+
+// adminReportFilters are the query parameters of the report triage list.
+type adminReportFilters struct {
+	Status     string
+	TargetType string
+	Reason     string
+	AssigneeID uint
+	Unassigned bool
+}
+
+func getAdminReportFilters(c *gin.Context) (f adminReportFilters, ok bool) {
+	switch f.Status = c.Query("status"); f.Status {
+	case "", database.ReportStatusOpen, database.ReportStatusResolved:
+	default:
+		utils.Fail(c, &utils.APIError{Code: http.StatusBadRequest, Message: "invalid status"}, nil)
+		return f, false
+	}
+
+	switch f.TargetType = c.Query("targetType"); f.TargetType {
+	case "", database.ReportTargetPost, database.ReportTargetComment, database.ReportTargetUser:
+	default:
+		utils.Fail(
+			c,
+			&utils.APIError{Code: http.StatusBadRequest, Message: "invalid target type"},
+			nil,
+		)
+		return f, false
+	}
+
+	f.Reason = c.Query("reason")
+	if f.Reason != "" && !slices.Contains(database.ReportReasons, f.Reason) {
+		utils.Fail(c, &utils.APIError{Code: http.StatusBadRequest, Message: "invalid reason"}, nil)
+		return f, false
+	}
+
+	if c.Query("assignee") == "none" {
+		f.Unassigned = true
+	} else if f.AssigneeID, ok = getOptionalQueryUInt(c, "assignee"); !ok {
+		return f, false
+	}
+
+	return f, true
+}
+
+// apply narrows a query on the reports table.
+func (f adminReportFilters) apply(q *gorm.DB) *gorm.DB {
+	if f.Status != "" {
+		q = q.Where("reports.status = ?", f.Status)
+	}
+	if f.TargetType != "" {
+		q = q.Where("reports.target_type = ?", f.TargetType)
+	}
+	if f.Reason != "" {
+		q = q.Where("reports.reason = ?", f.Reason)
+	}
+	if f.Unassigned {
+		q = q.Where("reports.assignee_id IS NULL")
+	} else if f.AssigneeID != 0 {
+		q = q.Where("reports.assignee_id = ?", f.AssigneeID)
+	}
+	return q
+}
+
+// getAdminReports lists reports for triage, oldest first so the ones that
+// waited the longest come up first.
+func (s *Server) getAdminReports(c *gin.Context) {
+	filters, ok := getAdminReportFilters(c)
+	if !ok {
+		return
+	}
+	after, ok := getCursor(c)
+	if !ok {
+		return
+	}
+	limit := getPageLimit(c)
+
+	q := filters.apply(s.db.Model(&database.Report{}))
+
+	var total int64
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	page := q.Session(&gorm.Session{}).
+		Preload("Reporter").
+		Preload("Assignee").
+		Preload("Resolver")
+	if after != nil {
+		page = page.Where("(reports.created_at, reports.id) > (?, ?)", after.Time, after.ID)
+	}
+
+	var reports []database.Report
+	err := page.Order("reports.created_at ASC, reports.id ASC").
+		Limit(limit + 1).
+		Find(&reports).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	nextCursor := ""
+	if len(reports) > limit {
+		reports = reports[:limit]
+		last := reports[limit-1]
+		nextCursor = encodeCursor(cursor{Time: last.CreatedAt, ID: last.ID})
+	}
+
+	response := make([]reportResponse, len(reports))
+	for i, r := range reports {
+		response[i] = newReportResponse(r)
+	}
+
+	utils.SuccessPageWithTotal(c, "", response, nextCursor, total)
+}
+
+// findOpenReport loads the report named by the "id" path parameter,
+// responding with the matching error when it is missing or already closed.
+func (s *Server) findOpenReport(c *gin.Context, tx *gorm.DB) (*database.Report, bool) {
+	reportID := convParamToInt(c, "id")
+	if reportID == 0 {
+		return nil, false
+	}
+
+	var report database.Report
+	if err := tx.First(&report, reportID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.Fail(
+				c,
+				&utils.APIError{Code: http.StatusNotFound, Message: "report not found"},
+				err,
+			)
+			return nil, false
+		}
+		utils.Fail(c, utils.ErrInternal, err)
+		return nil, false
+	}
+
+	if report.Status != database.ReportStatusOpen {
+		utils.Fail(
+			c,
+			&utils.APIError{Code: http.StatusConflict, Message: "report is already resolved"},
+			nil,
+		)
+		return nil, false
+	}
+
+	return &report, true
+}
+
+type assignReportReq struct {
+	// AssigneeID defaults to the caller. 0 unassigns the report.
+	AssigneeID *uint `json:"assigneeId"`
+}
+
+func (s *Server) assignReport(c *gin.Context) {
+	claims := getAccessClaims(c)
+	if claims == nil {
+		return
+	}
+
+	var req assignReportReq
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+
+	report, ok := s.findOpenReport(c, s.db)
+	if !ok {
+		return
+	}
+
+	var assigneeID *uint
+	if req.AssigneeID == nil {
+		id := convStrToUInt(c, claims.Subject, "user id")
+		if id == 0 {
+			return
+		}
+		assigneeID = &id
+	} else if *req.AssigneeID != 0 {
+		var assignee database.User
+		err := s.db.Select("id", "role").First(&assignee, *req.AssigneeID).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.Fail(c, utils.ErrInternal, err)
+			return
+		}
+		if err != nil || !rbac.Can(assignee.Role, rbac.ReportsManage) {
+			utils.Fail(
+				c,
+				&utils.APIError{
+					Code:    http.StatusBadRequest,
+					Message: "reports can only be assigned to moderators and admins",
+				},
+				err,
+			)
+			return
+		}
+		assigneeID = req.AssigneeID
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		before := gin.H{"assignee_id": report.AssigneeID}
+		if err := tx.Model(report).Update("assignee_id", assigneeID).Error; err != nil {
+			return err
+		}
+		return audit(c, tx, auditEntry{
+			Action:     database.AuditActionReportAssign,
+			TargetType: database.AuditTargetReport,
+			TargetID:   report.ID,
+			Before:     before,
+			After:      gin.H{"assignee_id": assigneeID},
+		})
+	})
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	if assigneeID == nil {
+		utils.Success(c, "report unassigned", nil)
+		return
+	}
+	utils.Success(c, "report assigned", nil)
+}
+
+type resolveReportReq struct {
+	Action string `json:"action" binding:"required"`
+	Note   string `json:"note"`
+}
+
+type resolveReportResponse struct {
+	// Resolved counts every open report on the same target, they are all
+	// closed with the same action.
+	Resolved int64 `json:"resolved"`
+}
+
+// resolveReport closes a report with the action taken. Deleting content and
+// banning users go through the same code as the dedicated endpoints.
+func (s *Server) resolveReport(c *gin.Context) {
+	claims := getAccessClaims(c)
+	if claims == nil {
+		return
+	}
+
+	var req resolveReportReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
+		return
+	}
+	switch req.Action {
+	case database.ReportActionDeleteContent,
+		database.ReportActionBanUser,
+		database.ReportActionDismiss:
+	default:
+		utils.Fail(c, &utils.APIError{Code: http.StatusBadRequest, Message: "invalid action"}, nil)
+		return
+	}
+
+	resolverID := convStrToUInt(c, claims.Subject, "user id")
+	if resolverID == 0 {
+		return
+	}
+
+	var (
+		resolved int64
+		// removal tells the author when their content gets removed.
+		removal *notificationEvent
+	)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		report, ok := s.findOpenReport(c, tx)
+		if !ok {
+			return errors.New("report can't be resolved")
+		}
+
+		effect, err := applyReportAction(tx, report, req.Action, resolverID, claims.Role)
+		if err == nil && effect != nil {
+			err = audit(c, tx, *effect)
+		}
+		if err == nil && req.Action == database.ReportActionDeleteContent {
+			removal, err = reportRemovalNotice(tx, report)
+		}
+		if err != nil {
+			var apiErr *utils.APIError
+			if errors.As(err, &apiErr) {
+				utils.Fail(c, apiErr, nil)
+			} else {
+				utils.Fail(c, utils.ErrInternal, err)
+			}
+			return err
+		}
+
+		now := time.Now()
+		result := tx.Model(&database.Report{}).
+			Where(
+				"target_type = ? AND target_id = ? AND status = ?",
+				report.TargetType,
+				report.TargetID,
+				database.ReportStatusOpen,
+			).
+			Updates(map[string]any{
+				"status":      database.ReportStatusResolved,
+				"action":      req.Action,
+				"note":        req.Note,
+				"resolver_id": resolverID,
+				"resolved_at": now,
+			})
+		if result.Error != nil {
+			utils.Fail(c, utils.ErrInternal, result.Error)
+			return result.Error
+		}
+		resolved = result.RowsAffected
+
+		err = audit(c, tx, auditEntry{
+			Action:     database.AuditActionReportResolve,
+			TargetType: database.AuditTargetReport,
+			TargetID:   report.ID,
+			Before:     gin.H{"status": report.Status},
+			After: gin.H{
+				"status":      database.ReportStatusResolved,
+				"action":      req.Action,
+				"note":        req.Note,
+				"target_type": report.TargetType,
+				"target_id":   report.TargetID,
+				"resolved":    resolved,
+			},
+		})
+		if err != nil {
+			utils.Fail(c, utils.ErrInternal, err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	if removal != nil {
+		s.notifier.notify(*removal)
+	}
+	utils.Success(c, "report resolved", resolveReportResponse{Resolved: resolved})
+}
+
+// reportRemovalNotice is the notification of the author of reported content
+// that got removed.
+func reportRemovalNotice(tx *gorm.DB, report *database.Report) (*notificationEvent, error) {
+	authorID, err := reportTargetAuthor(tx, report)
+	if err != nil {
+		return nil, err
+	}
+
+	notice := &notificationEvent{
+		Type:        database.NotificationModeration,
+		RecipientID: authorID,
+		TargetID:    report.TargetID,
+	}
+	if report.TargetType == database.ReportTargetPost {
+		notice.TargetType = database.NotificationTargetPost
+		notice.Message = "Your post was removed by a moderator following a report."
+	} else {
+		notice.TargetType = database.NotificationTargetComment
+		notice.Message = "Your comment was removed by a moderator following a report."
+	}
+	return notice, nil
+}
+
+// reportTargetAuthor returns who the reported content belongs to, deleted
+// content included. Reported users are their own authors.
+func reportTargetAuthor(tx *gorm.DB, report *database.Report) (uint, error) {
+	var model any
+	switch report.TargetType {
+	case database.ReportTargetUser:
+		return report.TargetID, nil
+	case database.ReportTargetPost:
+		model = &database.Post{}
+	default:
+		model = &database.Comment{}
+	}
+
+	var authorID uint
+	err := tx.Unscoped().Model(model).
+		Select("user_id").
+		Where("id = ?", report.TargetID).
+		Scan(&authorID).
+		Error
+	return authorID, err
+}
+
+// applyReportAction carries out the moderation action a report is resolved
+// with. Bans issued this way are permanent, and only reach users the
+// resolver outranks. It returns the audit entry of the action, which is nil
+// when nothing was done.
+func applyReportAction(
+	tx *gorm.DB,
+	report *database.Report,
+	action string,
+	resolverID uint,
+	resolverRole string,
+) (*auditEntry, error) {
+	switch action {
+	case database.ReportActionDeleteContent:
+		switch report.TargetType {
+		case database.ReportTargetPost:
+			var post database.Post
+			err := tx.First(&post, report.TargetID).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errReportTargetGone
+			}
+			if err != nil {
+				return nil, err
+			}
+			removed, err := removePost(tx, post.ID)
+			if err != nil {
+				return nil, err
+			}
+			if !removed {
+				return nil, errReportTargetGone
+			}
+			return &auditEntry{
+				Action:     database.AuditActionPostDelete,
+				TargetType: database.AuditTargetPost,
+				TargetID:   post.ID,
+				Before:     postSnapshot(&post),
+			}, nil
+
+		case database.ReportTargetComment:
+			var comment database.Comment
+			err := tx.Where("tombstoned = ?", false).First(&comment, report.TargetID).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errReportTargetGone
+			}
+			if err != nil {
+				return nil, err
+			}
+			before := commentSnapshot(&comment)
+			// Flagging holds the author's next comments for review.
+			if err := tx.Model(&comment).Update("flagged", true).Error; err != nil {
+				return nil, err
+			}
+			if err := removeComment(tx, &comment); err != nil {
+				return nil, err
+			}
+			return &auditEntry{
+				Action:     database.AuditActionCommentDelete,
+				TargetType: database.AuditTargetComment,
+				TargetID:   report.TargetID,
+				Before:     before,
+			}, nil
+
+		default:
+			return nil, &utils.APIError{
+				Code:    http.StatusBadRequest,
+				Message: "profiles can't be deleted, ban the user instead",
+			}
+		}
+
+	case database.ReportActionBanUser:
+		authorID, err := reportTargetAuthor(tx, report)
+		if err != nil {
+			return nil, err
+		}
+
+		var user database.User
+		err = tx.Select("id", "role", "banned").First(&user, authorID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errReportTargetGone
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !rbac.Outranks(resolverRole, user.Role) {
+			return nil, errBanNotAllowed
+		}
+		before, err := banSnapshot(tx, &user)
+		if err != nil {
+			return nil, err
+		}
+		reason := fmt.Sprintf("reported for %s (report #%d)", report.Reason, report.ID)
+		if err := banAccount(tx, user.ID, &resolverID, reason, nil); err != nil {
+			return nil, err
+		}
+		return &auditEntry{
+			Action:     database.AuditActionUserBan,
+			TargetType: database.AuditTargetUser,
+			TargetID:   user.ID,
+			Before:     before,
+			After:      banInfo{Reason: reason},
+		}, nil
+	}
+
+	return nil, nil
+}
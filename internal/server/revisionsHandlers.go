@@ -0,0 +1,335 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharon-xa/high-api/internal/database"
+	"github.com/sharon-xa/high-api/internal/rbac"
+	"github.com/sharon-xa/high-api/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type postRevisionResponse struct {
+	Number        uint       `json:"number"`
+	Title         string     `json:"title"`
+	Content       string     `json:"content,omitempty"`
+	ContentFormat string     `json:"content_format,omitempty"`
+	CategoryID    uint       `json:"category_id"`
+	Tags          []string   `json:"tags"`
+	Editor        *UserBrief `json:"editor"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func newPostRevisionResponse(r database.PostRevision, withContent bool) postRevisionResponse {
+	resp := postRevisionResponse{
+		Number:     r.Number,
+		Title:      r.Title,
+		CategoryID: r.CategoryID,
+		Tags:       splitRevisionTags(r.Tags),
+		CreatedAt:  r.CreatedAt,
+	}
+	if withContent {
+		resp.Content = r.Content
+		resp.ContentFormat = r.ContentFormat
+	}
+	if r.Editor != nil {
+		resp.Editor = &UserBrief{ID: r.Editor.ID, Name: r.Editor.Name, Image: r.Editor.Image}
+	}
+	return resp
+}
+
+func splitRevisionTags(tags string) []string {
+	if tags == "" {
+		return []string{}
+	}
+	return strings.Split(tags, ",")
+}
+
+// revisionText renders a snapshot as the text the revision diff runs on.
+func revisionText(r database.PostRevision) string {
+	return fmt.Sprintf(
+		"Title: %s\nCategory: %d\nTags: %s\n\n%s\n",
+		r.Title,
+		r.CategoryID,
+		r.Tags,
+		r.Content,
+	)
+}
+
+// recordRevision appends a snapshot of p, which must have its tags loaded,
+// to the post's history.
+func recordRevision(tx *gorm.DB, p *database.Post, editorID uint) error {
+	return createRevision(tx, p, &editorID, time.Now())
+}
+
+// ensureBaselineRevision snapshots posts created before revisions were
+// tracked, so their original content survives the first edit.
+func ensureBaselineRevision(tx *gorm.DB, p *database.Post) error {
+	var count int64
+	err := tx.Model(&database.PostRevision{}).Where("post_id = ?", p.ID).Count(&count).Error
+	if err != nil || count > 0 {
+		return err
+	}
+
+	authorID := p.UserID
+	return createRevision(tx, p, &authorID, p.UpdatedAt)
+}
+
+func createRevision(tx *gorm.DB, p *database.Post, editorID *uint, at time.Time) error {
+	// Lock the post so concurrent edits get consecutive revision numbers.
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").
+		First(&database.Post{}, p.ID).
+		Error
+	if err != nil {
+		return err
+	}
+
+	var last uint
+	err = tx.Model(&database.PostRevision{}).
+		Select("COALESCE(MAX(number), 0)").
+		Where("post_id = ?", p.ID).
+		Scan(&last).
+		Error
+	if err != nil {
+		return err
+	}
+
+	tagNames := make([]string, len(p.Tags))
+	for i, tag := range p.Tags {
+		tagNames[i] = tag.Name
+	}
+
+	return tx.Create(&database.PostRevision{
+		PostID:        p.ID,
+		Number:        last + 1,
+		EditorID:      editorID,
+		Title:         p.Title,
+		Content:       p.Content,
+		ContentFormat: p.ContentFormat,
+		CategoryID:    p.CategoryID,
+		Tags:          strings.Join(tagNames, ","),
+		CreatedAt:     at,
+	}).Error
+}
+
+// getPostForHistory loads the post named by the "id" path parameter for a
+// caller allowed to browse its history: the author or an admin.
+func (s *Server) getPostForHistory(c *gin.Context) (*database.Post, uint, bool) {
+	claims := getAccessClaims(c)
+	if claims == nil {
+		return nil, 0, false
+	}
+
+	postID := convParamToInt(c, "id")
+	if postID == 0 {
+		return nil, 0, false
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return nil, 0, false
+	}
+
+	var post database.Post
+	if err := s.db.Preload("Tags").First(&post, postID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.Fail(c, utils.ErrNotFound, err)
+			return nil, 0, false
+		}
+		utils.Fail(c, utils.ErrInternal, err)
+		return nil, 0, false
+	}
+
+	if post.UserID != uint(userID) && !rbac.Can(claims.Role, rbac.PostsHistoryAny) {
+		utils.Fail(
+			c,
+			&utils.APIError{
+				Code:    http.StatusForbidden,
+				Message: "you're not allowed to view the history of this post",
+			},
+			nil,
+		)
+		return nil, 0, false
+	}
+
+	return &post, uint(userID), true
+}
+
+// findRevision loads revision number of postID, responding with 404 when
+// it doesn't exist.
+func (s *Server) findRevision(c *gin.Context, postID, number uint) (*database.PostRevision, bool) {
+	var rev database.PostRevision
+	err := s.db.Preload("Editor").
+		Where("post_id = ? AND number = ?", postID, number).
+		First(&rev).
+		Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.Fail(
+				c,
+				&utils.APIError{Code: http.StatusNotFound, Message: "revision not found"},
+				err,
+			)
+			return nil, false
+		}
+		utils.Fail(c, utils.ErrInternal, err)
+		return nil, false
+	}
+
+	return &rev, true
+}
+
+func (s *Server) getPostRevisions(c *gin.Context) {
+	post, _, ok := s.getPostForHistory(c)
+	if !ok {
+		return
+	}
+
+	var revisions []database.PostRevision
+	err := s.db.Preload("Editor").
+		Omit("content").
+		Where("post_id = ?", post.ID).
+		Order("number DESC").
+		Find(&revisions).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	response := make([]postRevisionResponse, len(revisions))
+	for i, r := range revisions {
+		response[i] = newPostRevisionResponse(r, false)
+	}
+
+	utils.Success(c, "", response)
+}
+
+func (s *Server) getPostRevision(c *gin.Context) {
+	post, _, ok := s.getPostForHistory(c)
+	if !ok {
+		return
+	}
+
+	number := convParamToInt(c, "rev")
+	if number == 0 {
+		return
+	}
+
+	rev, ok := s.findRevision(c, post.ID, number)
+	if !ok {
+		return
+	}
+
+	utils.Success(c, "", newPostRevisionResponse(*rev, true))
+}
+
+type revisionDiffResponse struct {
+	From uint   `json:"from"`
+	To   uint   `json:"to"`
+	Diff string `json:"diff"`
+}
+
+// diffPostRevisions returns a unified diff between the revisions given by
+// the "from" and "to" query parameters.
+func (s *Server) diffPostRevisions(c *gin.Context) {
+	post, _, ok := s.getPostForHistory(c)
+	if !ok {
+		return
+	}
+
+	from := convStrToUInt(c, c.Query("from"), "from")
+	if from == 0 {
+		return
+	}
+	to := convStrToUInt(c, c.Query("to"), "to")
+	if to == 0 {
+		return
+	}
+
+	fromRev, ok := s.findRevision(c, post.ID, from)
+	if !ok {
+		return
+	}
+	toRev, ok := s.findRevision(c, post.ID, to)
+	if !ok {
+		return
+	}
+
+	utils.Success(c, "", revisionDiffResponse{
+		From: from,
+		To:   to,
+		Diff: utils.UnifiedDiff(
+			fmt.Sprintf("revision %d", from),
+			fmt.Sprintf("revision %d", to),
+			revisionText(*fromRev),
+			revisionText(*toRev),
+		),
+	})
+}
+
+// restorePostRevision brings the post back to an earlier snapshot. The
+// restore itself is recorded as a new revision, so it can be undone.
+func (s *Server) restorePostRevision(c *gin.Context) {
+	post, userID, ok := s.getPostForHistory(c)
+	if !ok {
+		return
+	}
+
+	number := convParamToInt(c, "rev")
+	if number == 0 {
+		return
+	}
+
+	rev, ok := s.findRevision(c, post.ID, number)
+	if !ok {
+		return
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := ensureBaselineRevision(tx, post); err != nil {
+			return err
+		}
+
+		titleChanged := post.Title != rev.Title || post.Slug == ""
+		post.Title = rev.Title
+		post.Content = rev.Content
+		post.CategoryID = rev.CategoryID
+
+		if apiErr := setPostContentFormat(post, rev.ContentFormat); apiErr != nil {
+			return apiErr
+		}
+
+		if titleChanged {
+			if err := updatePostSlug(tx, post); err != nil {
+				return err
+			}
+		}
+
+		if err := replacePostTags(tx, post, rev.Tags); err != nil {
+			return err
+		}
+		if err := tx.Save(post).Error; err != nil {
+			return err
+		}
+		return recordRevision(tx, post, userID)
+	})
+	if err != nil {
+		if !utils.ValidateFKey(c, err, "category") {
+			return
+		}
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	utils.Success(c, fmt.Sprintf("post restored to revision %d", number), nil)
+}
@@ -1,15 +1,21 @@
 package server
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/sharon-xa/high-api/internal/middleware"
+	"github.com/sharon-xa/high-api/internal/rbac"
 )
 
 func (s *Server) RegisterRoutes() http.Handler {
-	engine := gin.Default()
+	engine := gin.New()
+	engine.Use(gin.LoggerWithConfig(gin.LoggerConfig{Formatter: logFormatter}), gin.Recovery())
 
 	engine.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173"}, // Add your frontend URL
@@ -25,6 +31,49 @@ func (s *Server) RegisterRoutes() http.Handler {
 	return engine
 }
 
+// logFormatter is gin's default log format, with the values of "token"
+// query parameters left out. Stream and unsubscribe links carry tokens.
+func logFormatter(param gin.LogFormatterParams) string {
+	var statusColor, methodColor, resetColor string
+	if param.IsOutputColor() {
+		statusColor = param.StatusCodeColor()
+		methodColor = param.MethodColor()
+		resetColor = param.ResetColor()
+	}
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+
+	return fmt.Sprintf(
+		"[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, param.StatusCode, resetColor,
+		param.Latency,
+		param.ClientIP,
+		methodColor, param.Method, resetColor,
+		redactToken(param.Path),
+		param.ErrorMessage,
+	)
+}
+
+// redactToken replaces the value of the "token" query parameter of path.
+func redactToken(path string) string {
+	base, rawQuery, found := strings.Cut(path, "?")
+	if !found {
+		return path
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// Leave out what can't be parsed, it might hold a token.
+		return base
+	}
+	if !query.Has("token") {
+		return path
+	}
+	query.Set("token", "REDACTED")
+	return base + "?" + query.Encode()
+}
+
 func (s *Server) registerPublicRoutes(e *gin.Engine) {
 	auth := e.Group("/auth")
 
@@ -43,26 +92,63 @@ func (s *Server) registerPublicRoutes(e *gin.Engine) {
 
 	// public posts
 	posts := e.Group("/posts")
-	posts.GET("")
+	posts.Use(middleware.OptionalUser(s.env.AccessTokenSecret))
+	posts.GET("", s.getPosts)
+	posts.GET("/search", s.searchPosts)
+	posts.GET("/by-slug/:slug", s.getPostBySlug)
 	posts.GET("/:id", s.getPost)
 	posts.GET("/:id/comments", s.getCommentsOfPost)
 
+	// public comments
+	comments := e.Group("/comments")
+	comments.Use(middleware.OptionalUser(s.env.AccessTokenSecret))
+	comments.GET("/:id/replies", s.getCommentReplies)
+
 	// public categories
 	categories := e.Group("/categories")
 	categories.GET("", s.getCategories)
 
 	// public users
 	users := e.Group("/users")
+	users.Use(middleware.OptionalUser(s.env.AccessTokenSecret))
 	users.GET("/:id/public", s.getUserPublic)
+	users.GET("/:id/followers", s.getFollowers)
+	users.GET("/:id/following", s.getFollowing)
+
+	// The notification stream authenticates by itself, EventSource can't
+	// send the Authorization header. It takes a short-lived stream token in
+	// the query instead, kept out of the logs, and checks for bans again on
+	// every heartbeat.
+	e.GET("/notifications/stream", s.streamNotifications)
+
+	// email unsubscribe links
+	e.GET("/notifications/unsubscribe", s.getUnsubscribe)
+	e.POST("/notifications/unsubscribe", s.unsubscribe)
 
 	// public tags
 	tags := e.Group("/tags")
 	tags.GET("", s.getAllTags)
+
+	// search engines
+	e.GET("/robots.txt", s.getRobots)
+	e.GET("/sitemap.xml", s.getSitemapIndex)
+	e.GET("/sitemaps/:section/:page", s.getSitemap)
+
+	// syndication feeds
+	feeds := e.Group("/feeds")
+	feeds.GET("/rss.xml", s.feedHandler(feedFormatRSS, s.siteFeedScope))
+	feeds.GET("/atom.xml", s.feedHandler(feedFormatAtom, s.siteFeedScope))
+	feeds.GET("/categories/:id/rss.xml", s.feedHandler(feedFormatRSS, s.categoryFeedScope))
+	feeds.GET("/categories/:id/atom.xml", s.feedHandler(feedFormatAtom, s.categoryFeedScope))
+	feeds.GET("/tags/:name/rss.xml", s.feedHandler(feedFormatRSS, s.tagFeedScope))
+	feeds.GET("/tags/:name/atom.xml", s.feedHandler(feedFormatAtom, s.tagFeedScope))
+	feeds.GET("/users/:id/rss.xml", s.feedHandler(feedFormatRSS, s.authorFeedScope))
+	feeds.GET("/users/:id/atom.xml", s.feedHandler(feedFormatAtom, s.authorFeedScope))
 }
 
 func (s *Server) registerUserRoutes(e *gin.Engine) {
 	protected := e.Group("")
-	protected.Use(middleware.User(s.env.AccessTokenSecret))
+	protected.Use(middleware.User(s.env.AccessTokenSecret, s.db), s.rejectBannedUsers)
 
 	auth := protected.Group("/auth")
 	auth.POST("/logout", s.logout)
@@ -73,37 +159,79 @@ func (s *Server) registerUserRoutes(e *gin.Engine) {
 	users.PUT("/me", s.updateUser)
 	users.PATCH("/me/image", s.updateUserImage)
 	users.DELETE("/me", s.deleteUser)
+	users.GET("/me/drafts", s.getMyDrafts)
+	users.GET("/me/following/tags", s.getFollowedTags)
+	users.GET("/me/notification-preferences", s.getNotificationPreferences)
+	users.PUT("/me/notification-preferences", s.updateNotificationPreferences)
+	users.POST("/:id/follow", s.followUser)
+	users.DELETE("/:id/follow", s.unfollowUser)
+
+	protected.GET("/feed", s.getFeed)
+
+	notifications := protected.Group("/notifications")
+	notifications.GET("", s.getNotifications)
+	notifications.POST("/stream-token", s.getStreamToken)
+	notifications.POST("/read-all", s.readAllNotifications)
+	notifications.POST("/:id/read", s.readNotification)
+
+	tags := protected.Group("/tags")
+	tags.POST("/:id/follow", s.followTag)
+	tags.DELETE("/:id/follow", s.unfollowTag)
 
 	posts := protected.Group("/posts")
 	posts.POST("", s.addPost)
 	posts.PUT("/:id", s.updatePost)
 	posts.DELETE("/:id", s.deletePost)
+	posts.POST("/:id/publish", s.publishPost)
+	posts.GET("/:id/revisions", s.getPostRevisions)
+	posts.GET("/:id/revisions/diff", s.diffPostRevisions)
+	posts.GET("/:id/revisions/:rev", s.getPostRevision)
+	posts.POST("/:id/revisions/:rev/restore", s.restorePostRevision)
 	posts.POST("/:id/comment", s.addComment)
+	posts.POST("/:id/reactions", s.togglePostReaction)
+
+	protected.POST("/reports", s.addReport)
 
 	comments := protected.Group("/comments")
 	comments.PUT("/:id", s.updateComment)
 	comments.DELETE("/:id", s.deleteComment)
+	comments.POST("/:id/reactions", s.toggleCommentReaction)
 }
 
 func (s *Server) registerAdminRoutes(e *gin.Engine) {
 	admin := e.Group("/admin")
-	admin.Use(middleware.Admin(s.env.AccessTokenSecret))
+	admin.Use(middleware.User(s.env.AccessTokenSecret, s.db), s.rejectBannedUsers)
+
+	require := middleware.Require
+
+	admin.GET("/users", require(rbac.UsersView), s.getAllUsers)
+	admin.POST("/users/:id/ban", require(rbac.UsersBan), s.banUser)
+	admin.POST("/users/:id/unban", require(rbac.UsersBan), s.unbanUser)
+	admin.POST("/users/:id/promote", require(rbac.RolesManage), s.promoteUser)
+	admin.POST("/users/:id/demote", require(rbac.RolesManage), s.demoteUser)
+	admin.PUT("/users/:id/role", require(rbac.RolesManage), s.assignRole)
+
+	admin.DELETE("/posts/:id", require(rbac.PostsDeleteAny), s.deletePost)
 
-	admin.GET("/users", s.getAllUsers)
-	admin.POST("/users/:id/ban", s.banUser)
-	admin.POST("/users/:id/promote", s.promoteUser)
+	admin.GET("/comments", require(rbac.CommentsModerate), s.getAdminComments)
+	admin.POST("/comments/bulk", require(rbac.CommentsModerate), s.moderateComments)
+	admin.DELETE("/comments/:id", require(rbac.CommentsDeleteAny), s.deleteComment)
 
-	admin.DELETE("/posts/:id", s.deletePost)
+	admin.GET("/reports", require(rbac.ReportsManage), s.getAdminReports)
+	admin.POST("/reports/:id/assign", require(rbac.ReportsManage), s.assignReport)
+	admin.POST("/reports/:id/resolve", require(rbac.ReportsManage), s.resolveReport)
 
-	admin.GET("/comments")
-	admin.DELETE("/comments/:id", s.deleteComment)
+	admin.POST("/category", require(rbac.CategoriesManage), s.addCategory)
+	admin.PUT("/category/:id", require(rbac.CategoriesManage), s.updateCategory)
+	admin.DELETE("/category/:id", require(rbac.CategoriesManage), s.deleteCategory)
 
-	admin.POST("/category", s.addCategory)
-	admin.PUT("/category/:id", s.updateCategory)
-	admin.DELETE("/category/:id", s.deleteCategory)
+	admin.PUT("/tags/:id", require(rbac.TagsManage), s.updateTag)
+	admin.DELETE("/tags/:id", require(rbac.TagsManage), s.deleteTag)
 
-	admin.PUT("/tags/:id", s.updateTag)
-	admin.DELETE("/tags/:id", s.deleteTag)
+	admin.GET("/stat", require(rbac.StatsView), s.getStat)
+	admin.GET("/export/:resource", require(rbac.DataExport), s.exportData)
+	admin.GET("/audit", require(rbac.AuditView), s.getAuditEvents)
 
-	admin.GET("/stat")
+	admin.GET("/emails", require(rbac.EmailsManage), s.getOutboundEmails)
+	admin.POST("/emails/:id/requeue", require(rbac.EmailsManage), s.requeueOutboundEmail)
 }
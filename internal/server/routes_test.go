@@ -0,0 +1,45 @@
+package server
+
+import "testing"
+
+func TestRedactToken(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{
+			name: "no query",
+			path: "/notifications/stream",
+			want: "/notifications/stream",
+		},
+		{
+			name: "no token",
+			path: "/posts?page=2",
+			want: "/posts?page=2",
+		},
+		{
+			name: "token",
+			path: "/notifications/stream?token=abc.def.ghi",
+			want: "/notifications/stream?token=REDACTED",
+		},
+		{
+			name: "token among others",
+			path: "/notifications/unsubscribe?type=comment&token=abc",
+			want: "/notifications/unsubscribe?token=REDACTED&type=comment",
+		},
+		{
+			name: "unparsable query",
+			path: "/notifications/stream?token=%zz",
+			want: "/notifications/stream",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactToken(tt.path); got != tt.want {
+				t.Errorf("redactToken(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/sharon-xa/high-api/internal/database"
+)
+
+// job is a piece of background work the server runs periodically.
+type job struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+}
+
+func (s *Server) jobs() []job {
+	return []job{
+		{name: "publish scheduled posts", interval: time.Minute, run: s.publishScheduledPosts},
+		{name: "expire bans", interval: time.Minute, run: s.expireBans},
+		{name: "send notification digests", interval: time.Hour, run: s.sendDigests},
+		{name: "prune sent emails", interval: time.Hour, run: s.outbox.pruneSent},
+	}
+}
+
+// startScheduler runs every job on its own ticker until ctx is cancelled.
+func (s *Server) startScheduler(ctx context.Context) {
+	for _, j := range s.jobs() {
+		go func() {
+			ticker := time.NewTicker(j.interval)
+			defer ticker.Stop()
+
+			for {
+				if err := j.run(ctx); err != nil && ctx.Err() == nil {
+					log.Printf("Scheduler: %s failed: %v\n", j.name, err)
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+}
+
+func (s *Server) publishScheduledPosts(ctx context.Context) error {
+	return s.db.WithContext(ctx).
+		Model(&database.Post{}).
+		Where("status = ? AND publish_at <= ?", database.PostStatusScheduled, time.Now()).
+		Update("status", database.PostStatusPublished).
+		Error
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharon-xa/high-api/internal/database"
+	"github.com/sharon-xa/high-api/internal/utils"
+)
+
+const maxSearchQueryLen = 200
+
+// ts_headline doesn't escape the document, so matches are wrapped in control
+// characters first and turned into <mark> tags after escaping.
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+	headlineOpts   = "StartSel=\x02, StopSel=\x03, MaxFragments=2, MaxWords=30, MinWords=10"
+)
+
+type searchResult struct {
+	PostResponse
+	Snippet string  `json:"snippet"`
+	Rank    float64 `json:"rank"`
+}
+
+type searchHit struct {
+	ID      uint
+	Rank    float64
+	Snippet string
+}
+
+func (s *Server) searchPosts(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" || len(query) > maxSearchQueryLen {
+		utils.Fail(
+			c,
+			&utils.APIError{Code: http.StatusBadRequest, Message: "invalid search query"},
+			nil,
+		)
+		return
+	}
+
+	limit := getPageLimit(c)
+
+	var hits []searchHit
+	err := s.db.Model(&database.Post{}).
+		Scopes(publishedPosts).
+		Select(
+			"posts.id, ts_rank(posts.search_vector, tsq) AS rank, "+
+				"ts_headline('english', posts.content, tsq, ?) AS snippet",
+			headlineOpts,
+		).
+		Joins("CROSS JOIN websearch_to_tsquery('english', ?) AS tsq", query).
+		Where("posts.search_vector @@ tsq").
+		Order("rank DESC, posts.id DESC").
+		Offset(getPageOffset(c, limit)).
+		Limit(limit).
+		Scan(&hits).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	response := make([]searchResult, 0, len(hits))
+	if len(hits) == 0 {
+		utils.Success(c, "", response)
+		return
+	}
+
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+
+	var posts []database.Post
+	err = s.db.Preload("User").
+		Preload("Category").
+		Preload("Tags").
+		Where("id IN ?", ids).
+		Find(&posts).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	reactions, err := s.loadReactions(database.ReactionTargetPost, ids, getOptionalUserID(c))
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	postsByID := make(map[uint]database.Post, len(posts))
+	for _, p := range posts {
+		postsByID[p.ID] = p
+	}
+
+	for _, hit := range hits {
+		p, ok := postsByID[hit.ID]
+		if !ok {
+			continue
+		}
+		post := newPostResponse(p)
+		post.reactionSummary = reactions[p.ID]
+		response = append(response, searchResult{
+			PostResponse: post,
+			Snippet:      highlightSnippet(hit.Snippet),
+			Rank:         hit.Rank,
+		})
+	}
+
+	utils.Success(c, "", response)
+}
+
+func highlightSnippet(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, highlightStart, "<mark>")
+	return strings.ReplaceAll(snippet, highlightStop, "</mark>")
+}
@@ -1,6 +1,8 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -9,6 +11,7 @@ import (
 	_ "github.com/joho/godotenv/autoload"
 	"github.com/sharon-xa/high-api/internal/config"
 	"github.com/sharon-xa/high-api/internal/database"
+	"github.com/sharon-xa/high-api/internal/mail"
 	"github.com/sharon-xa/high-api/internal/s3"
 	"gorm.io/gorm"
 )
@@ -19,9 +22,15 @@ type Server struct {
 	db  *gorm.DB
 	env *config.Env
 	s3  *s3.S3Storage
+
+	outbox   *outbox
+	notifier *notifier
+	hub      *notificationHub
+
+	httpServer *http.Server
 }
 
-func NewServer() *http.Server {
+func NewServer() *Server {
 	env := config.NewEnv()
 
 	dbService := database.New(env.DSN)
@@ -33,12 +42,21 @@ func NewServer() *http.Server {
 		log.Fatalln(err)
 	}
 
+	mailer, err := mail.New(env)
+	if err != nil {
+		log.Println("Couldn't initialize the mailer")
+		log.Fatalln(err)
+	}
+
 	NewServer := &Server{
 		port: env.Port,
 		db:   dbService.DB(),
 		env:  env,
 		s3:   s3Storage,
+		hub:  newNotificationHub(),
 	}
+	NewServer.outbox = newOutbox(NewServer.db, mailer)
+	NewServer.notifier = newNotifier(NewServer.db, NewServer.hub, NewServer.emailNotifications)
 
 	// Declare Server config
 	server := &http.Server{
@@ -48,6 +66,29 @@ func NewServer() *http.Server {
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
 	}
+	NewServer.httpServer = server
+
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	NewServer.startScheduler(schedulerCtx)
+	server.RegisterOnShutdown(stopScheduler)
+
+	// Stopping the notifier also ends the notification streams, which would
+	// otherwise keep the shutdown waiting.
+	notifierCtx, stopNotifier := context.WithCancel(context.Background())
+	go NewServer.notifier.run(notifierCtx)
+	server.RegisterOnShutdown(stopNotifier)
+
+	NewServer.outbox.start()
+
+	return NewServer
+}
+
+func (s *Server) ListenAndServe() error {
+	return s.httpServer.ListenAndServe()
+}
 
-	return server
+// Shutdown stops the server gracefully: it waits for the requests being
+// handled, then for the emails being sent, until ctx ends.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return errors.Join(s.httpServer.Shutdown(ctx), s.outbox.shutdown(ctx))
 }
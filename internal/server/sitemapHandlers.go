@@ -0,0 +1,198 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharon-xa/high-api/internal/database"
+	"github.com/sharon-xa/high-api/internal/sitemap"
+	"github.com/sharon-xa/high-api/internal/utils"
+	"gorm.io/gorm"
+)
+
+// sitemapSection is a kind of page listed in the sitemap. Each section is
+// split into as many sitemaps as the URL limit requires.
+type sitemapSection struct {
+	name string
+	// query selects the section's rows, with a last_modified column.
+	query func() *gorm.DB
+	// loc builds the frontend URL of a row.
+	loc func(row sitemapRow) string
+}
+
+type sitemapRow struct {
+	ID           uint
+	Key          string
+	LastModified *time.Time
+}
+
+func (s *Server) sitemapSections() []sitemapSection {
+	return []sitemapSection{
+		{
+			name: "posts",
+			query: func() *gorm.DB {
+				return s.db.Model(&database.Post{}).
+					Scopes(publishedPosts).
+					Select("posts.id, posts.slug AS key, posts.updated_at AS last_modified")
+			},
+			loc: func(row sitemapRow) string {
+				return fmt.Sprintf("%s/posts/%s", s.env.FrontendURL, row.Key)
+			},
+		},
+		{
+			name: "categories",
+			query: func() *gorm.DB {
+				return s.db.Model(&database.Category{}).
+					Select("categories.id, categories.name AS key, MAX(posts.updated_at) AS last_modified").
+					Joins(
+						"LEFT JOIN posts ON posts.category_id = categories.id "+
+							"AND posts.status = ? AND posts.deleted_at IS NULL",
+						database.PostStatusPublished,
+					).
+					Group("categories.id")
+			},
+			loc: func(row sitemapRow) string {
+				return fmt.Sprintf("%s/categories/%d", s.env.FrontendURL, row.ID)
+			},
+		},
+		{
+			name: "tags",
+			query: func() *gorm.DB {
+				return s.db.Model(&database.Tag{}).
+					Select("tags.id, tags.name AS key, MAX(posts.updated_at) AS last_modified").
+					Joins("JOIN post_tags ON post_tags.tag_id = tags.id").
+					Joins(
+						"JOIN posts ON posts.id = post_tags.post_id "+
+							"AND posts.status = ? AND posts.deleted_at IS NULL",
+						database.PostStatusPublished,
+					).
+					Group("tags.id")
+			},
+			loc: func(row sitemapRow) string {
+				return fmt.Sprintf("%s/tags/%s", s.env.FrontendURL, url.PathEscape(row.Key))
+			},
+		},
+		{
+			name: "users",
+			query: func() *gorm.DB {
+				return s.db.Model(&database.User{}).
+					Select("users.id, users.name AS key, users.updated_at AS last_modified").
+					Where("users.verified = ? AND users.banned = ?", true, false)
+			},
+			loc: func(row sitemapRow) string {
+				return fmt.Sprintf("%s/users/%d", s.env.FrontendURL, row.ID)
+			},
+		},
+	}
+}
+
+// getSitemapIndex lists one sitemap per MaxURLs rows of every section.
+func (s *Server) getSitemapIndex(c *gin.Context) {
+	var sitemaps []sitemap.URL
+
+	for _, section := range s.sitemapSections() {
+		var state struct {
+			Count        int64
+			LastModified *time.Time
+		}
+		err := s.db.Table("(?) AS section", section.query()).
+			Select("COUNT(*) AS count, MAX(section.last_modified) AS last_modified").
+			Scan(&state).
+			Error
+		if err != nil {
+			utils.Fail(c, utils.ErrInternal, err)
+			return
+		}
+
+		pages := (state.Count + sitemap.MaxURLs - 1) / sitemap.MaxURLs
+		for page := int64(1); page <= pages; page++ {
+			entry := sitemap.URL{
+				Loc: fmt.Sprintf("%s/sitemaps/%s/%d.xml", s.env.FrontendURL, section.name, page),
+			}
+			if state.LastModified != nil {
+				entry.LastMod = *state.LastModified
+			}
+			sitemaps = append(sitemaps, entry)
+		}
+	}
+
+	writeSitemap(c, func(w io.Writer) error {
+		return sitemap.WriteIndex(w, sitemaps)
+	})
+}
+
+// getSitemap serves /sitemaps/:section/:page, where page looks like "1.xml".
+func (s *Server) getSitemap(c *gin.Context) {
+	var section *sitemapSection
+	for _, candidate := range s.sitemapSections() {
+		if candidate.name == c.Param("section") {
+			section = &candidate
+			break
+		}
+	}
+
+	pageStr, isXML := strings.CutSuffix(c.Param("page"), ".xml")
+	page, err := strconv.Atoi(pageStr)
+	if section == nil || !isXML || err != nil || page < 1 {
+		utils.Fail(c, utils.ErrNotFound, err)
+		return
+	}
+
+	var rows []sitemapRow
+	err = section.query().
+		Order(section.name + ".id").
+		Offset((page - 1) * sitemap.MaxURLs).
+		Limit(sitemap.MaxURLs).
+		Scan(&rows).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+	if len(rows) == 0 {
+		utils.Fail(c, utils.ErrNotFound, nil)
+		return
+	}
+
+	urls := make([]sitemap.URL, len(rows))
+	for i, row := range rows {
+		urls[i].Loc = section.loc(row)
+		if row.LastModified != nil {
+			urls[i].LastMod = *row.LastModified
+		}
+	}
+
+	writeSitemap(c, func(w io.Writer) error {
+		return sitemap.Write(w, urls)
+	})
+}
+
+func writeSitemap(c *gin.Context, write func(w io.Writer) error) {
+	var body bytes.Buffer
+	if err := write(&body); err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", body.Bytes())
+}
+
+// getRobots serves the robots.txt of the frontend. Crawlers only read it,
+// and only take sitemaps, from the host of the pages, so the frontend has
+// to proxy /robots.txt, /sitemap.xml and /sitemaps/ to the API. The sitemap
+// URLs point at the frontend accordingly.
+func (s *Server) getRobots(c *gin.Context) {
+	robots := fmt.Sprintf(
+		"User-agent: *\nDisallow: /admin/\nDisallow: /auth/\n\nSitemap: %s/sitemap.xml\n",
+		s.env.FrontendURL,
+	)
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(robots))
+}
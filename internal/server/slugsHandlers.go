@@ -0,0 +1,127 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharon-xa/high-api/internal/database"
+	"github.com/sharon-xa/high-api/internal/utils"
+	"gorm.io/gorm"
+)
+
+// uniquePostSlug derives a slug from title that no other post uses or used
+// to use, appending "-2", "-3", ... on collisions.
+func uniquePostSlug(tx *gorm.DB, title string, postID uint) (string, error) {
+	base := utils.Slugify(title)
+	if base == "" {
+		base = "post"
+	}
+
+	// Slugs only hold letters, digits and hyphens, so they are safe in LIKE.
+	var current, retired []string
+	err := tx.Model(&database.Post{}).
+		Unscoped().
+		Where("id <> ? AND (slug = ? OR slug LIKE ?)", postID, base, base+"-%").
+		Pluck("slug", &current).
+		Error
+	if err != nil {
+		return "", err
+	}
+	err = tx.Model(&database.PostSlug{}).
+		Where("post_id <> ? AND (slug = ? OR slug LIKE ?)", postID, base, base+"-%").
+		Pluck("slug", &retired).
+		Error
+	if err != nil {
+		return "", err
+	}
+
+	taken := make(map[string]bool, len(current)+len(retired))
+	for _, slug := range append(current, retired...) {
+		taken[slug] = true
+	}
+
+	slug := base
+	for n := 2; taken[slug]; n++ {
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+	return slug, nil
+}
+
+// updatePostSlug regenerates the slug of p from its title and retires the
+// previous one. It is meant to run when the title changes; the caller saves p.
+func updatePostSlug(tx *gorm.DB, p *database.Post) error {
+	slug, err := uniquePostSlug(tx, p.Title, p.ID)
+	if err != nil || slug == p.Slug {
+		return err
+	}
+
+	// A post may take back one of its own retired slugs.
+	err = tx.Where("post_id = ? AND slug = ?", p.ID, slug).Delete(&database.PostSlug{}).Error
+	if err != nil {
+		return err
+	}
+
+	if p.Slug != "" {
+		if err := tx.Create(&database.PostSlug{PostID: p.ID, Slug: p.Slug}).Error; err != nil {
+			return err
+		}
+	}
+
+	p.Slug = slug
+	return nil
+}
+
+type slugRedirectResponse struct {
+	ID   uint   `json:"id"`
+	Slug string `json:"slug"`
+}
+
+// getPostBySlug resolves a permalink. A retired slug gets a 301 pointing at
+// the post's current slug, both in the Location header and in the body.
+func (s *Server) getPostBySlug(c *gin.Context) {
+	slug := c.Param("slug")
+
+	var p database.Post
+	err := s.db.Scopes(publishedPosts).
+		Preload("User").
+		Preload("Category").
+		Preload("Tags").
+		Where("slug = ?", slug).
+		First(&p).
+		Error
+	if err == nil {
+		response := []PostResponse{newPostResponse(p)}
+		if err := s.attachPostReactions(c, response); err != nil {
+			utils.Fail(c, utils.ErrInternal, err)
+			return
+		}
+		utils.Success(c, "", response[0])
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	var current slugRedirectResponse
+	err = s.db.Model(&database.Post{}).
+		Scopes(publishedPosts).
+		Select("posts.id", "posts.slug").
+		Joins("JOIN post_slugs ON post_slugs.post_id = posts.id").
+		Where("post_slugs.slug = ?", slug).
+		Take(&current).
+		Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.Fail(c, utils.ErrNotFound, err)
+			return
+		}
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	c.Header("Location", "/posts/by-slug/"+current.Slug)
+	utils.Respond(c, http.StatusMovedPermanently, true, "post has moved", current, nil)
+}
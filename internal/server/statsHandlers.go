@@ -0,0 +1,249 @@
+package server
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharon-xa/high-api/internal/database"
+	"github.com/sharon-xa/high-api/internal/utils"
+)
+
+const (
+	defaultStatRange = 30 * 24 * time.Hour
+	maxStatPoints    = 400
+	topStatSize      = 10
+)
+
+// statIntervals maps the accepted "interval" values to their step.
+var statIntervals = map[string]time.Duration{
+	"day":  24 * time.Hour,
+	"week": 7 * 24 * time.Hour,
+}
+
+type statTotals struct {
+	Users         int64 `json:"users"`
+	VerifiedUsers int64 `json:"verified_users"`
+	BannedUsers   int64 `json:"banned_users"`
+	Posts         int64 `json:"posts"`
+	Comments      int64 `json:"comments"`
+	Tags          int64 `json:"tags"`
+	Categories    int64 `json:"categories"`
+}
+
+// statTotalsQuery counts the live rows of each table. Only users, posts and
+// comments are soft-deleted.
+const statTotalsQuery = `
+	SELECT
+		(SELECT COUNT(*) FROM users WHERE deleted_at IS NULL) AS users,
+		(SELECT COUNT(*) FROM users WHERE deleted_at IS NULL AND verified) AS verified_users,
+		(SELECT COUNT(*) FROM users WHERE deleted_at IS NULL AND banned) AS banned_users,
+		(SELECT COUNT(*) FROM posts WHERE deleted_at IS NULL) AS posts,
+		(SELECT COUNT(*) FROM comments WHERE deleted_at IS NULL) AS comments,
+		(SELECT COUNT(*) FROM tags) AS tags,
+		(SELECT COUNT(*) FROM categories) AS categories`
+
+type statPoint struct {
+	Bucket time.Time `json:"bucket"`
+	Count  int64     `json:"count"`
+}
+
+type statSeries struct {
+	Signups  []statPoint `json:"signups"`
+	Posts    []statPoint `json:"posts"`
+	Comments []statPoint `json:"comments"`
+}
+
+type topAuthor struct {
+	UserBrief
+	Posts int64 `json:"posts"`
+}
+
+type topCategory struct {
+	CategoryBrief
+	Posts int64 `json:"posts"`
+}
+
+type statResponse struct {
+	Totals        statTotals    `json:"totals"`
+	Interval      string        `json:"interval"`
+	From          time.Time     `json:"from"`
+	To            time.Time     `json:"to"`
+	Series        statSeries    `json:"series"`
+	TopAuthors    []topAuthor   `json:"top_authors"`
+	TopCategories []topCategory `json:"top_categories"`
+}
+
+// getStat serves the admin dashboard: site wide totals, plus the activity
+// between the "from" and "to" query parameters (the last 30 days by default)
+// bucketed by "interval" (day or week).
+func (s *Server) getStat(c *gin.Context) {
+	interval := c.DefaultQuery("interval", "day")
+	step, ok := statIntervals[interval]
+	if !ok {
+		utils.Fail(c, &utils.APIError{Code: http.StatusBadRequest, Message: "invalid interval"}, nil)
+		return
+	}
+
+	from, ok := getOptionalQueryTime(c, "from", false)
+	if !ok {
+		return
+	}
+	to, ok := getOptionalQueryTime(c, "to", true)
+	if !ok {
+		return
+	}
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.Add(-defaultStatRange)
+	}
+	if !from.Before(to) {
+		utils.Fail(
+			c,
+			&utils.APIError{Code: http.StatusBadRequest, Message: "from must be before to"},
+			nil,
+		)
+		return
+	}
+	if to.Sub(from)/step > maxStatPoints {
+		utils.Fail(
+			c,
+			&utils.APIError{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("the range can't span more than %d %ss", maxStatPoints, interval),
+			},
+			nil,
+		)
+		return
+	}
+
+	response := statResponse{Interval: interval, From: from, To: to}
+
+	err := s.db.Raw(statTotalsQuery).Scan(&response.Totals).Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	series := []struct {
+		points *[]statPoint
+		table  string
+		column string
+		where  string
+		args   map[string]any
+	}{
+		{&response.Series.Signups, "users", "created_at", "TRUE", nil},
+		{
+			&response.Series.Posts,
+			"posts",
+			"publish_at",
+			"status = @status",
+			map[string]any{"status": database.PostStatusPublished},
+		},
+		{&response.Series.Comments, "comments", "created_at", "TRUE", nil},
+	}
+	for _, sr := range series {
+		points, err := s.countSeries(sr.table, sr.column, sr.where, sr.args, interval, from, to)
+		if err != nil {
+			utils.Fail(c, utils.ErrInternal, err)
+			return
+		}
+		*sr.points = points
+	}
+
+	var authors []struct {
+		ID    uint
+		Name  string
+		Image string
+		Posts int64
+	}
+	err = s.db.Model(&database.Post{}).
+		Scopes(publishedPosts).
+		Select("users.id, users.name, users.image, COUNT(*) AS posts").
+		Joins("JOIN users ON users.id = posts.user_id").
+		Where("posts.publish_at >= ? AND posts.publish_at < ?", from, to).
+		Group("users.id").
+		Order("posts DESC, users.id").
+		Limit(topStatSize).
+		Scan(&authors).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	response.TopAuthors = make([]topAuthor, len(authors))
+	for i, a := range authors {
+		response.TopAuthors[i] = topAuthor{
+			UserBrief: UserBrief{ID: a.ID, Name: a.Name, Image: a.Image},
+			Posts:     a.Posts,
+		}
+	}
+
+	var categories []struct {
+		ID    uint
+		Name  string
+		Posts int64
+	}
+	err = s.db.Model(&database.Post{}).
+		Scopes(publishedPosts).
+		Select("categories.id, categories.name, COUNT(*) AS posts").
+		Joins("JOIN categories ON categories.id = posts.category_id").
+		Where("posts.publish_at >= ? AND posts.publish_at < ?", from, to).
+		Group("categories.id").
+		Order("posts DESC, categories.id").
+		Limit(topStatSize).
+		Scan(&categories).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	response.TopCategories = make([]topCategory, len(categories))
+	for i, cat := range categories {
+		response.TopCategories[i] = topCategory{
+			CategoryBrief: CategoryBrief{ID: cat.ID, Name: cat.Name},
+			Posts:         cat.Posts,
+		}
+	}
+
+	utils.Success(c, "", response)
+}
+
+// countSeries counts the live rows of table matching where, bucketed by
+// column. where can refer to the named parameters in args. Buckets without
+// rows are reported with a count of 0.
+func (s *Server) countSeries(
+	table, column, where string,
+	args map[string]any,
+	interval string,
+	from, to time.Time,
+) ([]statPoint, error) {
+	query := fmt.Sprintf(`
+		SELECT buckets.bucket, COUNT(t.id) AS count
+		FROM generate_series(
+			date_trunc(@interval, CAST(@from AS timestamptz)),
+			CAST(@to AS timestamptz) - interval '1 microsecond',
+			CAST('1 ' || @interval AS interval)
+		) AS buckets(bucket)
+		LEFT JOIN (
+			SELECT id, %[2]s FROM %[1]s
+			WHERE deleted_at IS NULL AND %[2]s >= @from AND %[2]s < @to AND (%[3]s)
+		) AS t ON date_trunc(@interval, t.%[2]s) = buckets.bucket
+		GROUP BY buckets.bucket
+		ORDER BY buckets.bucket`,
+		table, column, where,
+	)
+
+	named := map[string]any{"interval": interval, "from": from, "to": to}
+	maps.Copy(named, args)
+
+	var points []statPoint
+	err := s.db.Raw(query, named).Scan(&points).Error
+	return points, err
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sharon-xa/high-api/internal/config"
+	"github.com/sharon-xa/high-api/internal/database"
+	"gorm.io/gorm/schema"
+)
+
+func TestGetStat(t *testing.T) {
+	db := testDB(t)
+	s := &Server{db: db, env: &config.Env{}}
+
+	category := database.Category{Name: "go"}
+	if err := db.Create(&category).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/admin/stat?interval=day", nil)
+
+	s.getStat(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("getStat() status = %d, body %s", w.Code, w.Body)
+	}
+}
+
+// TestStatTotalsQuerySoftDeletes runs without a database: it checks that
+// the totals only filter on deleted_at in tables that have the column.
+func TestStatTotalsQuerySoftDeletes(t *testing.T) {
+	softDeleted := map[string]bool{}
+	for _, model := range database.Models() {
+		sch, err := schema.Parse(model, &sync.Map{}, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		softDeleted[sch.Table] = sch.LookUpField("DeletedAt") != nil
+	}
+
+	tables := regexp.MustCompile(`FROM (\w+)( WHERE deleted_at)?`).
+		FindAllStringSubmatch(statTotalsQuery, -1)
+	if len(tables) == 0 {
+		t.Fatal("no tables found in the totals query")
+	}
+	for _, m := range tables {
+		table, filtered := m[1], m[2] != ""
+		has, ok := softDeleted[table]
+		if !ok {
+			t.Errorf("the totals count %s, which has no model", table)
+			continue
+		}
+		if filtered != has {
+			t.Errorf("%s: filters on deleted_at = %v, has the column = %v", table, filtered, has)
+		}
+	}
+}
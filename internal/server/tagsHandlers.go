@@ -57,9 +57,22 @@ func (s *Server) updateTag(c *gin.Context) {
 		return
 	}
 
+	before := gin.H{"name": tag.Name}
 	tag.Name = strings.ToLower(strings.TrimSpace(req.Name))
 
-	if err := s.db.Save(&tag).Error; err != nil {
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&tag).Error; err != nil {
+			return err
+		}
+		return audit(c, tx, auditEntry{
+			Action:     database.AuditActionTagUpdate,
+			TargetType: database.AuditTargetTag,
+			TargetID:   tag.ID,
+			Before:     before,
+			After:      gin.H{"name": tag.Name},
+		})
+	})
+	if err != nil {
 		if strings.Contains(err.Error(), "duplicate key") {
 			utils.Fail(c, &utils.APIError{
 				Code:    http.StatusConflict,
@@ -80,12 +93,26 @@ func (s *Server) deleteTag(c *gin.Context) {
 		return
 	}
 
-	results := s.db.Delete(&database.Tag{}, tagID)
-	if results.Error != nil {
-		utils.Fail(c, utils.ErrInternal, results.Error)
+	var tag database.Tag
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&tag, tagID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&tag).Error; err != nil {
+			return err
+		}
+		return audit(c, tx, auditEntry{
+			Action:     database.AuditActionTagDelete,
+			TargetType: database.AuditTargetTag,
+			TargetID:   tag.ID,
+			Before:     gin.H{"name": tag.Name},
+		})
+	})
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.Fail(c, utils.ErrInternal, err)
 		return
 	}
-	if results.RowsAffected == 0 {
+	if err != nil {
 		utils.Fail(
 			c,
 			&utils.APIError{Code: http.StatusNotFound, Message: "category not found"},
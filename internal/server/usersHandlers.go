@@ -2,60 +2,162 @@ package server
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sharon-xa/high-api/internal/database"
+	"github.com/sharon-xa/high-api/internal/mail"
+	"github.com/sharon-xa/high-api/internal/rbac"
 	"github.com/sharon-xa/high-api/internal/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type adminUserResponse struct {
-	ID        uint      `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Gender    string    `json:"gender"`
-	Image     string    `json:"image"`
-	Bio       string    `json:"bio"`
-	Role      string    `json:"role"`
-	Verified  bool      `json:"verified"`
-	CreatedAt time.Time `json:"created_at"`
+	ID           uint      `json:"id"`
+	Name         string    `json:"name"`
+	Email        string    `json:"email"`
+	Gender       string    `json:"gender"`
+	Image        string    `json:"image"`
+	Bio          string    `json:"bio"`
+	Role         string    `json:"role"`
+	Verified     bool      `json:"verified"`
+	Banned       bool      `json:"banned"`
+	PostCount    int64     `json:"post_count"`
+	CommentCount int64     `json:"comment_count"`
+	CreatedAt    time.Time `json:"created_at"`
 }
 
+// adminUserFilters are the query parameters of the admin user list. The
+// user export takes them as well, so changing them changes both.
+type adminUserFilters struct {
+	// Search matches the start of names and emails.
+	Search   string
+	Role     string
+	Verified *bool
+	Banned   *bool
+	// From and To bound the signup date.
+	From time.Time
+	To   time.Time
+}
+
+func getAdminUserFilters(c *gin.Context) (f adminUserFilters, ok bool) {
+	f.Search = strings.ToLower(strings.TrimSpace(c.Query("q")))
+	f.Role = c.Query("role")
+
+	if f.Verified, ok = getOptionalQueryBool(c, "verified"); !ok {
+		return f, false
+	}
+	if f.Banned, ok = getOptionalQueryBool(c, "banned"); !ok {
+		return f, false
+	}
+	if f.From, ok = getOptionalQueryTime(c, "from", false); !ok {
+		return f, false
+	}
+	if f.To, ok = getOptionalQueryTime(c, "to", true); !ok {
+		return f, false
+	}
+
+	return f, true
+}
+
+// apply narrows a query on the users table.
+func (f adminUserFilters) apply(q *gorm.DB) *gorm.DB {
+	if f.Search != "" {
+		pattern := escapeLike(f.Search) + "%"
+		q = q.Where("lower(users.name) LIKE ? OR lower(users.email) LIKE ?", pattern, pattern)
+	}
+	if f.Role != "" {
+		q = q.Where("users.role = ?", f.Role)
+	}
+	if f.Verified != nil {
+		q = q.Where("users.verified = ?", *f.Verified)
+	}
+	if f.Banned != nil {
+		q = q.Where("users.banned = ?", *f.Banned)
+	}
+	if !f.From.IsZero() {
+		q = q.Where("users.created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		q = q.Where("users.created_at < ?", f.To)
+	}
+	return q
+}
+
+// adminUserSorts maps the accepted "sort" values to the column they sort by.
+var adminUserSorts = map[string]string{
+	"created_at": "users.created_at",
+	"name":       "users.name",
+	"email":      "users.email",
+	"posts":      "post_count",
+	"comments":   "comment_count",
+}
+
+// getAllUsers lists users a page at a time, sorted by the "sort" query
+// parameter in the "order" direction (newest signups first by default).
 func (s *Server) getAllUsers(c *gin.Context) {
-	var users []database.User
+	filters, ok := getAdminUserFilters(c)
+	if !ok {
+		return
+	}
 
-	err := s.db.Find(&users).Error
-	if err != nil {
+	sortColumn, ok := adminUserSorts[c.DefaultQuery("sort", "created_at")]
+	if !ok {
+		utils.Fail(c, &utils.APIError{Code: http.StatusBadRequest, Message: "invalid sort"}, nil)
+		return
+	}
+	order := c.DefaultQuery("order", "desc")
+	if order != "asc" && order != "desc" {
+		utils.Fail(c, &utils.APIError{Code: http.StatusBadRequest, Message: "invalid order"}, nil)
+		return
+	}
+
+	limit := getPageLimit(c)
+	q := filters.apply(s.db.Model(&database.User{}))
+
+	var total int64
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
 		utils.Fail(c, utils.ErrInternal, err)
 		return
 	}
 
-	var response []adminUserResponse
-	for _, u := range users {
-		response = append(response, adminUserResponse{
-			ID:        u.ID,
-			Name:      u.Name,
-			Email:     u.Email,
-			Gender:    u.Gender,
-			Image:     u.Image,
-			Bio:       u.Bio,
-			Role:      u.Role,
-			Verified:  u.Verified,
-			CreatedAt: u.CreatedAt,
-		})
+	response := make([]adminUserResponse, 0, limit)
+	err := q.Session(&gorm.Session{}).
+		Select(
+			"users.id, users.name, users.email, users.gender, users.image, users.bio, " +
+				"users.role, users.verified, users.banned, users.created_at, " +
+				"(SELECT COUNT(*) FROM posts WHERE posts.user_id = users.id " +
+				"AND posts.deleted_at IS NULL) AS post_count, " +
+				"(SELECT COUNT(*) FROM comments WHERE comments.user_id = users.id " +
+				"AND comments.deleted_at IS NULL) AS comment_count",
+		).
+		Order(fmt.Sprintf("%s %s, users.id %s", sortColumn, order, order)).
+		Offset(getPageOffset(c, limit)).
+		Limit(limit).
+		Scan(&response).
+		Error
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
 	}
 
-	// TODO: the users needs to be paginated
-	utils.Success(c, "", response)
+	utils.SuccessPageWithTotal(c, "", response, "", total)
 }
 
 type publicUserResponse struct {
-	ID    uint   `json:"id"`
-	Name  string `json:"name"`
-	Image string `json:"image"`
-	Bio   string `json:"bio"`
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	Image     string `json:"image"`
+	Bio       string `json:"bio"`
+	Followers int64  `json:"followers" gorm:"-"`
+	Following int64  `json:"following" gorm:"-"`
+	// Followed tells signed in callers whether they follow the user.
+	Followed bool `json:"followed" gorm:"-"`
 }
 
 func (s *Server) getUserPublic(c *gin.Context) {
@@ -80,6 +182,25 @@ func (s *Server) getUserPublic(c *gin.Context) {
 		return
 	}
 
+	user.Followers, user.Following, err = s.followCounts(user.ID)
+	if err != nil {
+		utils.Fail(c, utils.ErrInternal, err)
+		return
+	}
+
+	if callerID := getOptionalUserID(c); callerID != 0 {
+		err := s.db.Model(&database.Follow{}).
+			Select("1").
+			Where("follower_id = ? AND followee_id = ?", callerID, user.ID).
+			Limit(1).
+			Find(&user.Followed).
+			Error
+		if err != nil {
+			utils.Fail(c, utils.ErrInternal, err)
+			return
+		}
+	}
+
 	utils.Success(c, "", user)
 }
 
@@ -91,6 +212,7 @@ type userResponse struct {
 	Bio    string `json:"bio"`
 	Email  string `json:"email"`
 	Role   string `json:"role"`
+	Lang   string `json:"language"`
 }
 
 func (s *Server) getUser(c *gin.Context) {
@@ -118,6 +240,7 @@ func (s *Server) getUser(c *gin.Context) {
 		Bio:    u.Bio,
 		Gender: u.Gender,
 		Role:   u.Role,
+		Lang:   u.Language,
 	}
 
 	utils.Success(c, "", response)
@@ -127,6 +250,8 @@ type updateUserReq struct {
 	Name   string `json:"name"`
 	Gender string `json:"gender"`
 	Bio    string `json:"bio"`
+	Lang   string `json:"language"`
+	// the language is left as it is when empty.
 }
 
 func (s *Server) updateUser(c *gin.Context) {
@@ -152,6 +277,11 @@ func (s *Server) updateUser(c *gin.Context) {
 		return
 	}
 
+	if req.Lang != "" && !mail.SupportedLocale(req.Lang) {
+		utils.Fail(c, errUnsupportedLanguage, nil)
+		return
+	}
+
 	var user database.User
 	if err = s.db.First(&user, claims.Subject).Error; err != nil {
 		utils.Fail(c, utils.ErrInternal, err)
@@ -161,6 +291,9 @@ func (s *Server) updateUser(c *gin.Context) {
 	user.Name = req.Name
 	user.Bio = req.Bio
 	user.Gender = req.Gender
+	if req.Lang != "" {
+		user.Language = req.Lang
+	}
 
 	err = s.db.Save(&user).Error
 	if err != nil {
@@ -221,7 +354,16 @@ func (s *Server) deleteUser(c *gin.Context) {
 		return
 	}
 
-	err := s.db.Unscoped().Delete(&database.User{}, claims.Subject).Error
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// The user's reactions go away with the cascade, the counts don't.
+		if err := releaseUserReactions(tx, claims.Subject); err != nil {
+			return err
+		}
+		if err := deleteUserContentReactions(tx, claims.Subject); err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&database.User{}, claims.Subject).Error
+	})
 	if err != nil {
 		utils.Fail(c, utils.ErrInternal, err)
 		return
@@ -230,53 +372,155 @@ func (s *Server) deleteUser(c *gin.Context) {
 	utils.Success(c, "user is deleted successfully", nil)
 }
 
-func (s *Server) banUser(c *gin.Context) {
-	userID := convParamToInt(c, "id")
-	if userID == 0 {
-		return
-	}
+func (s *Server) promoteUser(c *gin.Context) {
+	s.changeRole(c, func(current string) (string, bool) {
+		i := slices.Index(rbac.Roles, current)
+		if i == len(rbac.Roles)-1 {
+			return "", false
+		}
+		return rbac.Roles[i+1], true
+	})
+}
 
-	var user database.User
-	if err := s.db.First(&user, userID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			utils.Fail(c, utils.ErrNotFound, err)
-			return
+func (s *Server) demoteUser(c *gin.Context) {
+	s.changeRole(c, func(current string) (string, bool) {
+		i := slices.Index(rbac.Roles, current)
+		if i <= 0 {
+			return "", false
 		}
-		utils.Fail(c, utils.ErrInternal, err)
+		return rbac.Roles[i-1], true
+	})
+}
+
+type assignRoleReq struct {
+	Role string `json:"role" binding:"required"`
+}
+
+func (s *Server) assignRole(c *gin.Context) {
+	var req assignRoleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, utils.ErrBadRequest, err)
 		return
 	}
-
-	user.Banned = true
-	if err := s.db.Save(&user).Error; err != nil {
-		utils.Fail(c, utils.ErrInternal, err)
+	if !rbac.ValidRole(req.Role) {
+		utils.Fail(c, &utils.APIError{Code: http.StatusBadRequest, Message: "invalid role"}, nil)
 		return
 	}
 
-	utils.Success(c, "User banned successfully", nil)
+	s.changeRole(c, func(string) (string, bool) { return req.Role, true })
 }
 
-func (s *Server) promoteUser(c *gin.Context) {
+// changeRole sets the role of the user in the "id" path parameter to the one
+// next returns for their current role, next returns false when there is no
+// such role. Admins can't change their own role, and the last admin can't
+// be demoted, so the site always keeps one.
+func (s *Server) changeRole(c *gin.Context, next func(current string) (string, bool)) {
+	claims := getAccessClaims(c)
+	if claims == nil {
+		return
+	}
+
 	userID := convParamToInt(c, "id")
 	if userID == 0 {
 		return
 	}
 
-	var user database.User
-	if err := s.db.First(&user, userID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			utils.Fail(c, utils.ErrNotFound, err)
-			return
-		}
-		utils.Fail(c, utils.ErrInternal, err)
+	actorID := convStrToUInt(c, claims.Subject, "user id")
+	if actorID == 0 {
+		return
+	}
+	if actorID == userID {
+		utils.Fail(
+			c,
+			&utils.APIError{Code: http.StatusBadRequest, Message: "you can't change your own role"},
+			nil,
+		)
 		return
 	}
 
-	user.Role = "admin"
+	var role string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var user database.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "role").
+			First(&user, userID).
+			Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				utils.Fail(c, utils.ErrNotFound, err)
+				return err
+			}
+			utils.Fail(c, utils.ErrInternal, err)
+			return err
+		}
 
-	if err := s.db.Save(&user).Error; err != nil {
-		utils.Fail(c, utils.ErrInternal, err)
+		var ok bool
+		role, ok = next(user.Role)
+		if !ok {
+			apiErr := &utils.APIError{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("a %s can't be moved any further", user.Role),
+			}
+			utils.Fail(c, apiErr, nil)
+			return apiErr
+		}
+		if role == user.Role {
+			return nil
+		}
+
+		if user.Role == rbac.RoleAdmin {
+			// Lock the admins so that two demotions can't race each
+			// other past the check.
+			var admins []database.User
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Select("id").
+				Where("role = ?", rbac.RoleAdmin).
+				Find(&admins).
+				Error
+			if err != nil {
+				utils.Fail(c, utils.ErrInternal, err)
+				return err
+			}
+			if len(admins) <= 1 {
+				apiErr := &utils.APIError{
+					Code:    http.StatusConflict,
+					Message: "the last admin can't be demoted",
+				}
+				utils.Fail(c, apiErr, nil)
+				return apiErr
+			}
+		}
+
+		before := gin.H{"role": user.Role}
+		if err := tx.Model(&user).Update("role", role).Error; err != nil {
+			utils.Fail(c, utils.ErrInternal, err)
+			return err
+		}
+
+		// Sessions carry the role, the user has to log in again to get
+		// the new one.
+		err = tx.Where("user_id = ?", user.ID).Delete(&database.RefreshToken{}).Error
+		if err != nil {
+			utils.Fail(c, utils.ErrInternal, err)
+			return err
+		}
+
+		err = audit(c, tx, auditEntry{
+			Action:     database.AuditActionUserRole,
+			TargetType: database.AuditTargetUser,
+			TargetID:   user.ID,
+			Before:     before,
+			After:      gin.H{"role": role},
+		})
+		if err != nil {
+			utils.Fail(c, utils.ErrInternal, err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
 		return
 	}
 
-	utils.Success(c, "User promoted successfully", nil)
+	utils.Success(c, "User role updated successfully", gin.H{"role": role})
 }
@@ -0,0 +1,63 @@
+package sitemap
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// MaxURLs is the most URLs the sitemaps protocol allows in one sitemap.
+const MaxURLs = 50000
+
+const namespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// URL is an entry of a sitemap, or a sitemap listed in a sitemap index.
+type URL struct {
+	Loc     string
+	LastMod time.Time
+}
+
+type entry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type urlSet struct {
+	XMLName xml.Name `xml:"urlset"`
+	XMLNS   string   `xml:"xmlns,attr"`
+	URLs    []entry  `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name `xml:"sitemapindex"`
+	XMLNS    string   `xml:"xmlns,attr"`
+	Sitemaps []entry  `xml:"sitemap"`
+}
+
+// Write writes a sitemap listing urls to w.
+func Write(w io.Writer, urls []URL) error {
+	return write(w, urlSet{XMLNS: namespace, URLs: entries(urls)})
+}
+
+// WriteIndex writes a sitemap index pointing at sitemaps to w.
+func WriteIndex(w io.Writer, sitemaps []URL) error {
+	return write(w, sitemapIndex{XMLNS: namespace, Sitemaps: entries(sitemaps)})
+}
+
+func entries(urls []URL) []entry {
+	out := make([]entry, len(urls))
+	for i, u := range urls {
+		out[i].Loc = u.Loc
+		if !u.LastMod.IsZero() {
+			out[i].LastMod = u.LastMod.UTC().Format(time.RFC3339)
+		}
+	}
+	return out
+}
+
+func write(w io.Writer, doc any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(doc)
+}
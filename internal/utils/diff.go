@@ -0,0 +1,209 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	diffContextLines = 3
+	// Past this many edits the diff degrades to replacing every line, which
+	// keeps the memory used by the edit trace bounded.
+	maxDiffEdits = 1000
+)
+
+type diffOp int
+
+const (
+	diffEqual diffOp = iota
+	diffDelete
+	diffInsert
+)
+
+type diffLine struct {
+	op   diffOp
+	text string
+}
+
+// UnifiedDiff returns a line based diff from a to b in the unified format,
+// with three lines of context around every change. It returns an empty
+// string when both texts are equal.
+func UnifiedDiff(fromName, toName, a, b string) string {
+	if a == b {
+		return ""
+	}
+
+	lines := diffLines(splitLines(a), splitLines(b))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	writeHunks(&sb, lines)
+
+	return sb.String()
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// diffLines finds a shortest edit script turning a into b using Myers'
+// algorithm.
+func diffLines(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	maxD := min(n+m, maxDiffEdits)
+	offset := maxD + 1
+
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+
+	for d := 0; d <= maxD; d++ {
+		trace = append(trace, append([]int(nil), v...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrackDiff(trace, offset, a, b)
+			}
+		}
+	}
+
+	lines := make([]diffLine, 0, n+m)
+	for _, line := range a {
+		lines = append(lines, diffLine{op: diffDelete, text: line})
+	}
+	for _, line := range b {
+		lines = append(lines, diffLine{op: diffInsert, text: line})
+	}
+	return lines
+}
+
+func backtrackDiff(trace [][]int, offset int, a, b []string) []diffLine {
+	x, y := len(a), len(b)
+	var lines []diffLine
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			lines = append(lines, diffLine{op: diffEqual, text: a[x-1]})
+			x--
+			y--
+		}
+
+		if d > 0 {
+			if x == prevX {
+				lines = append(lines, diffLine{op: diffInsert, text: b[y-1]})
+			} else {
+				lines = append(lines, diffLine{op: diffDelete, text: a[x-1]})
+			}
+		}
+
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
+
+func writeHunks(sb *strings.Builder, lines []diffLine) {
+	// aPos[i] and bPos[i] are the number of lines of a and b before lines[i].
+	aPos := make([]int, len(lines)+1)
+	bPos := make([]int, len(lines)+1)
+	for i, line := range lines {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if line.op != diffInsert {
+			aPos[i+1]++
+		}
+		if line.op != diffDelete {
+			bPos[i+1]++
+		}
+	}
+
+	for i := 0; i < len(lines); {
+		if lines[i].op == diffEqual {
+			i++
+			continue
+		}
+
+		start := max(i-diffContextLines, 0)
+		end := i
+		for end < len(lines) {
+			if lines[end].op != diffEqual {
+				end++
+				continue
+			}
+
+			run := end
+			for run < len(lines) && lines[run].op == diffEqual {
+				run++
+			}
+			if run == len(lines) || run-end > 2*diffContextLines {
+				end = min(end+diffContextLines, len(lines))
+				break
+			}
+			end = run
+		}
+
+		fmt.Fprintf(
+			sb,
+			"@@ -%s +%s @@\n",
+			hunkRange(aPos[start], aPos[end]-aPos[start]),
+			hunkRange(bPos[start], bPos[end]-bPos[start]),
+		)
+		for _, line := range lines[start:end] {
+			switch line.op {
+			case diffEqual:
+				sb.WriteString(" ")
+			case diffDelete:
+				sb.WriteString("-")
+			case diffInsert:
+				sb.WriteString("+")
+			}
+			sb.WriteString(line.text)
+			sb.WriteString("\n")
+		}
+
+		i = end
+	}
+}
+
+// hunkRange formats the line range of a hunk. Line numbers are 1-based,
+// except for empty ranges which point at the line before them.
+func hunkRange(pos, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", pos)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", pos+1)
+	}
+	return fmt.Sprintf("%d,%d", pos+1, count)
+}
@@ -0,0 +1,80 @@
+package utils
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "empty inputs",
+			want: "",
+		},
+		{
+			name: "equal inputs",
+			a:    "one\ntwo\n",
+			b:    "one\ntwo\n",
+			want: "",
+		},
+		{
+			name: "insert only",
+			b:    "one\ntwo\n",
+			want: "--- a\n+++ b\n" +
+				"@@ -0,0 +1,2 @@\n" +
+				"+one\n" +
+				"+two\n",
+		},
+		{
+			name: "delete only",
+			a:    "one\ntwo\n",
+			want: "--- a\n+++ b\n" +
+				"@@ -1,2 +0,0 @@\n" +
+				"-one\n" +
+				"-two\n",
+		},
+		{
+			name: "replace in the middle",
+			a:    "1\n2\n3\n4\n5\n6\n7\n",
+			b:    "1\n2\n3\nX\n5\n6\n7\n",
+			want: "--- a\n+++ b\n" +
+				"@@ -1,7 +1,7 @@\n" +
+				" 1\n 2\n 3\n" +
+				"-4\n+X\n" +
+				" 5\n 6\n 7\n",
+		},
+		{
+			name: "close changes share a hunk",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			b:    "1\n2\n3\nX\n5\n6\n7\n8\n9\nY\n",
+			want: "--- a\n+++ b\n" +
+				"@@ -1,10 +1,10 @@\n" +
+				" 1\n 2\n 3\n" +
+				"-4\n+X\n" +
+				" 5\n 6\n 7\n 8\n 9\n" +
+				"-10\n+Y\n",
+		},
+		{
+			name: "distant changes get their own hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			b:    "X\n2\n3\n4\n5\n6\n7\n8\n9\n10\nY\n",
+			want: "--- a\n+++ b\n" +
+				"@@ -1,4 +1,4 @@\n" +
+				"-1\n+X\n" +
+				" 2\n 3\n 4\n" +
+				"@@ -8,4 +8,4 @@\n" +
+				" 8\n 9\n 10\n" +
+				"-11\n+Y\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := UnifiedDiff("a", "b", tt.a, tt.b)
+			if got != tt.want {
+				t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
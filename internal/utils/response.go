@@ -8,10 +8,12 @@ import (
 )
 
 type APIResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
-	Data    any    `json:"data,omitempty"`
-	Error   any    `json:"error,omitempty"`
+	Success    bool   `json:"success"`
+	Message    string `json:"message"`
+	Data       any    `json:"data,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
+	Error      any    `json:"error,omitempty"`
 }
 
 func Respond(
@@ -61,6 +63,35 @@ func Success(c *gin.Context, message string, data any) {
 	Respond(c, http.StatusOK, true, message, data, nil)
 }
 
+// SuccessPage responds with one page of a cursor-paginated collection.
+// An empty nextCursor means there are no more pages.
+func SuccessPage(c *gin.Context, message string, data any, nextCursor string) {
+	c.JSON(http.StatusOK, APIResponse{
+		Success:    true,
+		Message:    message,
+		Data:       data,
+		NextCursor: nextCursor,
+	})
+}
+
+// SuccessPageWithTotal is SuccessPage for collections that also report how
+// many items they hold in total.
+func SuccessPageWithTotal(
+	c *gin.Context,
+	message string,
+	data any,
+	nextCursor string,
+	total int64,
+) {
+	c.JSON(http.StatusOK, APIResponse{
+		Success:    true,
+		Message:    message,
+		Data:       data,
+		NextCursor: nextCursor,
+		Total:      &total,
+	})
+}
+
 func Created(c *gin.Context, message string, data any) {
 	Respond(c, http.StatusCreated, true, message, data, nil)
 }
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const maxSlugLen = 80
+
+// transliterations covers letters that don't decompose into an ASCII base
+// letter plus combining marks. Runes mapped to "" are dropped without
+// breaking the word.
+var transliterations = map[rune]string{
+	'\'': "", '’': "",
+
+	// Latin
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'ł': "l", 'đ': "d", 'ð': "d",
+	'þ': "th", 'ı': "i", 'ŋ': "ng", 'ħ': "h",
+
+	// Cyrillic
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya", 'є': "ye",
+	'і': "i", 'ї': "yi", 'ґ': "g",
+
+	// Greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i",
+	'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x",
+	'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y",
+	'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+
+	// Arabic
+	'ا': "a", 'أ': "a", 'إ': "i", 'آ': "a", 'ب': "b", 'ت': "t", 'ث': "th",
+	'ج': "j", 'ح': "h", 'خ': "kh", 'د': "d", 'ذ': "dh", 'ر': "r", 'ز': "z",
+	'س': "s", 'ش': "sh", 'ص': "s", 'ض': "d", 'ط': "t", 'ظ': "z", 'ع': "a",
+	'غ': "gh", 'ف': "f", 'ق': "q", 'ك': "k", 'ل': "l", 'م': "m", 'ن': "n",
+	'ه': "h", 'و': "w", 'ي': "y", 'ى': "a", 'ة': "a", 'ء': "", 'ئ': "y",
+	'ؤ': "w",
+}
+
+// Slugify turns s into a lowercase, URL-safe slug made of ASCII letters,
+// digits and single hyphens. Accented letters lose their accents and a few
+// scripts are transliterated; anything else is dropped. The result may be
+// empty.
+func Slugify(s string) string {
+	var sb strings.Builder
+	pendingHyphen := false
+
+	write := func(part string) {
+		if part == "" {
+			return
+		}
+		if pendingHyphen && sb.Len() > 0 {
+			sb.WriteByte('-')
+		}
+		pendingHyphen = false
+		sb.WriteString(part)
+	}
+
+	// Letters such as й or أ are transliterated whole, only the ones
+	// without an entry are decomposed to get at their base letter.
+	for _, composed := range norm.NFC.String(strings.ToLower(s)) {
+		runes := []rune{composed}
+		if _, ok := transliterations[composed]; !ok {
+			runes = []rune(norm.NFKD.String(string(composed)))
+		}
+
+		for _, r := range runes {
+			switch {
+			case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+				write(string(r))
+			case unicode.Is(unicode.Mn, r):
+				// combining marks left over from decomposing accented letters
+			case transliterations[r] != "":
+				write(transliterations[r])
+			default:
+				if _, ok := transliterations[r]; !ok {
+					pendingHyphen = true
+				}
+			}
+		}
+	}
+
+	slug := sb.String()
+	if len(slug) > maxSlugLen {
+		slug = slug[:maxSlugLen]
+		if i := strings.LastIndexByte(slug, '-'); i > maxSlugLen/2 {
+			slug = slug[:i]
+		}
+		slug = strings.TrimSuffix(slug, "-")
+	}
+
+	return slug
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "punctuation", in: "Hello, World!", want: "hello-world"},
+		{name: "surrounding separators", in: "  --Go   is fun--  ", want: "go-is-fun"},
+		{name: "accents", in: "Crème Brûlée", want: "creme-brulee"},
+		{name: "latin letters without a base", in: "Straße", want: "strasse"},
+		{name: "apostrophes", in: "Don't stop", want: "dont-stop"},
+		{name: "cyrillic", in: "Привет мир", want: "privet-mir"},
+		{name: "cyrillic short i", in: "Йога", want: "yoga"},
+		{name: "cyrillic yo", in: "ёлка", want: "yolka"},
+		{name: "ukrainian yi", in: "Їжак", want: "yizhak"},
+		{name: "greek", in: "Καλημέρα", want: "kalimera"},
+		{name: "arabic", in: "مرحبا بالعالم", want: "mrhba-balaalm"},
+		{name: "arabic hamza below", in: "إسلام", want: "islam"},
+		{name: "arabic hamza above", in: "أمل", want: "aml"},
+		{name: "arabic madda", in: "آمن", want: "amn"},
+		{name: "arabic hamza on ya and waw", in: "بئر مؤمن", want: "byr-mwmn"},
+		{name: "nothing left", in: "!!!", want: ""},
+		{name: "empty", in: "", want: ""},
+		{
+			name: "long titles are cut between words",
+			in:   strings.Repeat("word ", 30),
+			want: strings.TrimSuffix(strings.Repeat("word-", 16), "-"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Slugify(tt.in); got != tt.want {
+				t.Errorf("Slugify(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}